@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/bitrise-io/go-xcode/plistutil"
+	"github.com/bitrise-io/go-xcode/xcarchive"
+)
+
+// macosArchive extends xcarchive.MacosArchive with every application of the archive,
+// xcarchive.NewMacosArchive only parses the first one.
+type macosArchive struct {
+	xcarchive.MacosArchive
+	// Applications contains the main application (archive.Application) first, then the companion applications.
+	Applications []xcarchive.MacosApplication
+}
+
+// newMacosArchive parses the archive and every application in it.
+// The main application is selected by mainAppBundleID if set, otherwise it is the application
+// referenced by ApplicationProperties.ApplicationPath in the archive's Info.plist, or the first application found.
+func newMacosArchive(archivePath string, appPths []string, mainAppBundleID string) (macosArchive, error) {
+	infoPlistPath := filepath.Join(archivePath, "Info.plist")
+	infoPlist, err := plistutil.NewPlistDataFromFile(infoPlistPath)
+	if err != nil {
+		return macosArchive{}, fmt.Errorf("failed to parse Info.plist (%s): %s", infoPlistPath, err)
+	}
+
+	var applications []xcarchive.MacosApplication
+	for _, appPth := range appPths {
+		app, err := xcarchive.NewMacosApplication(appPth)
+		if err != nil {
+			return macosArchive{}, err
+		}
+		applications = append(applications, app)
+	}
+
+	mainAppIdx, err := mainApplicationIndex(archivePath, infoPlist, applications, mainAppBundleID)
+	if err != nil {
+		return macosArchive{}, err
+	}
+
+	applications[0], applications[mainAppIdx] = applications[mainAppIdx], applications[0]
+
+	return macosArchive{
+		MacosArchive: xcarchive.MacosArchive{
+			Path:        archivePath,
+			InfoPlist:   infoPlist,
+			Application: applications[0],
+		},
+		Applications: applications,
+	}, nil
+}
+
+func mainApplicationIndex(archivePath string, infoPlist plistutil.PlistData, applications []xcarchive.MacosApplication, mainAppBundleID string) (int, error) {
+	if len(applications) == 0 {
+		return 0, fmt.Errorf("no application found in the archive")
+	}
+
+	if mainAppBundleID != "" {
+		for i, app := range applications {
+			if app.BundleIdentifier() == mainAppBundleID {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("no application found with bundle ID: %s", mainAppBundleID)
+	}
+
+	if properties, found := infoPlist.GetMapStringInterface("ApplicationProperties"); found {
+		if applicationPath, found := properties.GetString("ApplicationPath"); found {
+			mainAppPth := filepath.Join(archivePath, "Products", applicationPath)
+			for i, app := range applications {
+				if filepath.Clean(app.Path) == filepath.Clean(mainAppPth) {
+					return i, nil
+				}
+			}
+		}
+	}
+
+	return 0, nil
+}
+
+// AppPaths returns the path of every application, the main application first.
+func (archive macosArchive) AppPaths() []string {
+	var pths []string
+	for _, app := range archive.Applications {
+		pths = append(pths, app.Path)
+	}
+	return pths
+}
+
+// BundleIDEntitlementsMap ...
+func (archive macosArchive) BundleIDEntitlementsMap() map[string]plistutil.PlistData {
+	bundleIDEntitlementsMap := map[string]plistutil.PlistData{}
+	for _, app := range archive.Applications {
+		bundleIDEntitlementsMap[app.BundleIdentifier()] = app.Entitlements
+
+		for _, plugin := range app.Extensions {
+			bundleIDEntitlementsMap[plugin.BundleIdentifier()] = plugin.Entitlements
+		}
+	}
+	return bundleIDEntitlementsMap
+}
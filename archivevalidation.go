@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-io/go-xcode/plistutil"
+	"github.com/bitrise-io/go-xcode/xcarchive"
+)
+
+// nestedBundleExtensions lists the extensions of the bundle types which have to contain an Info.plist.
+var nestedBundleExtensions = []string{".app", ".appex", ".bundle", ".framework", ".kext", ".mdimporter", ".plugin", ".prefPane", ".qlgenerator", ".saver", ".xpc"}
+
+// archiveValidationError lists every inconsistency found in the archive.
+type archiveValidationError struct {
+	violations []string
+}
+
+func (e archiveValidationError) Error() string {
+	return fmt.Sprintf("archive validation failed with %d issue(s):\n- %s", len(e.violations), strings.Join(e.violations, "\n- "))
+}
+
+// validate checks the archive's consistency before export and reports every violation at once.
+func (archive macosArchive) validate() error {
+	var violations []string
+
+	violations = append(violations, archive.validateApplicationPath()...)
+
+	for _, app := range archive.Applications {
+		violations = append(violations, validateExecutable(app.Path, app.InfoPlist)...)
+		violations = append(violations, validateNestedBundles(app.Path)...)
+
+		shortVersion, _ := app.InfoPlist.GetString("CFBundleShortVersionString")
+		version, _ := app.InfoPlist.GetString("CFBundleVersion")
+		for _, extension := range app.Extensions {
+			violations = append(violations, validateExecutable(extension.Path, extension.InfoPlist)...)
+			violations = append(violations, validateExtension(app, extension, shortVersion, version)...)
+		}
+	}
+
+	if len(violations) > 0 {
+		return archiveValidationError{violations: violations}
+	}
+	return nil
+}
+
+func (archive macosArchive) validateApplicationPath() []string {
+	properties, found := archive.InfoPlist.GetMapStringInterface("ApplicationProperties")
+	if !found {
+		return []string{"ApplicationProperties not found in the archive's Info.plist"}
+	}
+
+	applicationPath, found := properties.GetString("ApplicationPath")
+	if !found {
+		return []string{"ApplicationProperties.ApplicationPath not found in the archive's Info.plist"}
+	}
+
+	// the main app might be a companion app selected by its bundle ID, so any app of the archive is accepted
+	expected := filepath.Clean(filepath.Join(archive.Path, "Products", applicationPath))
+	for _, app := range archive.Applications {
+		if filepath.Clean(app.Path) == expected {
+			return nil
+		}
+	}
+
+	return []string{fmt.Sprintf("ApplicationProperties.ApplicationPath (%s) does not point to an app of the archive", applicationPath)}
+}
+
+func validateExecutable(bundlePth string, infoPlist plistutil.PlistData) []string {
+	bundleName := filepath.Base(bundlePth)
+
+	executableName, found := infoPlist.GetString("CFBundleExecutable")
+	if !found || executableName == "" {
+		return []string{fmt.Sprintf("%s: CFBundleExecutable not found in Info.plist", bundleName)}
+	}
+
+	executablePth := filepath.Join(bundlePth, "Contents/MacOS", executableName)
+	if exist, err := pathutil.IsPathExists(executablePth); err != nil {
+		return []string{fmt.Sprintf("%s: failed to check if executable exists at: %s, error: %s", bundleName, executablePth, err)}
+	} else if !exist {
+		return []string{fmt.Sprintf("%s: executable (%s) does not exist", bundleName, executableName)}
+	}
+
+	if machO, err := isMachO(executablePth); err != nil {
+		return []string{fmt.Sprintf("%s: failed to read executable (%s), error: %s", bundleName, executableName, err)}
+	} else if !machO {
+		return []string{fmt.Sprintf("%s: executable (%s) is not a Mach-O binary", bundleName, executableName)}
+	}
+
+	return nil
+}
+
+func validateExtension(app xcarchive.MacosApplication, extension xcarchive.MacosExtension, shortVersion, version string) []string {
+	var violations []string
+	extensionName := filepath.Base(extension.Path)
+
+	if extensionShortVersion, _ := extension.InfoPlist.GetString("CFBundleShortVersionString"); extensionShortVersion != shortVersion {
+		violations = append(violations, fmt.Sprintf("%s: CFBundleShortVersionString (%s) does not match the app's (%s)", extensionName, extensionShortVersion, shortVersion))
+	}
+	if extensionVersion, _ := extension.InfoPlist.GetString("CFBundleVersion"); extensionVersion != version {
+		violations = append(violations, fmt.Sprintf("%s: CFBundleVersion (%s) does not match the app's (%s)", extensionName, extensionVersion, version))
+	}
+	if !strings.HasPrefix(extension.BundleIdentifier(), app.BundleIdentifier()+".") {
+		violations = append(violations, fmt.Sprintf("%s: bundle ID (%s) is not prefixed by the app's bundle ID (%s)", extensionName, extension.BundleIdentifier(), app.BundleIdentifier()))
+	}
+
+	return violations
+}
+
+// validateNestedBundles checks that every bundle embedded into the app has an Info.plist.
+func validateNestedBundles(appPth string) []string {
+	var violations []string
+	if err := filepath.Walk(appPth, func(pth string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if pth == appPth || !info.IsDir() || !isNestedBundle(pth) {
+			return nil
+		}
+
+		if !bundleHasInfoPlist(pth) {
+			relPth, err := filepath.Rel(filepath.Dir(appPth), pth)
+			if err != nil {
+				return err
+			}
+			violations = append(violations, fmt.Sprintf("%s: nested bundle has no Info.plist", relPth))
+		}
+		return nil
+	}); err != nil {
+		violations = append(violations, fmt.Sprintf("failed to walk %s: %s", filepath.Base(appPth), err))
+	}
+
+	return violations
+}
+
+func isNestedBundle(pth string) bool {
+	ext := filepath.Ext(pth)
+	for _, bundleExt := range nestedBundleExtensions {
+		if ext == bundleExt {
+			return true
+		}
+	}
+	return false
+}
+
+func bundleHasInfoPlist(bundlePth string) bool {
+	// frameworks keep their Info.plist under (Versions/Current/)Resources
+	for _, infoPlistPth := range []string{"Contents/Info.plist", "Resources/Info.plist", "Versions/Current/Resources/Info.plist"} {
+		if exist, err := pathutil.IsPathExists(filepath.Join(bundlePth, infoPlistPth)); err == nil && exist {
+			return true
+		}
+	}
+	return false
+}
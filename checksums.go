@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-export-xcarchive-mac/utils"
+)
+
+const (
+	bitriseSHA256SumsPathEnvKey       = "BITRISE_SHA256SUMS_PATH"
+	bitriseArtifactManifestPathEnvKey = "BITRISE_ARTIFACT_MANIFEST_PATH"
+)
+
+// artifactManifest is the content of the JSON manifest listing the exported artifacts.
+type artifactManifest struct {
+	Artifacts []utils.ExportedArtifact `json:"artifacts"`
+}
+
+// exportChecksums writes the checksums of every artifact exported so far into a SHA256SUMS file and a JSON manifest.
+func exportChecksums(sha256SumsPth, manifestPth string) error {
+	artifacts := utils.ExportedArtifacts()
+	if len(artifacts) == 0 {
+		log.Warnf("No artifact exported, skipping checksums")
+		return nil
+	}
+
+	for _, artifact := range artifacts {
+		log.Printf("- %s  %s", artifact.SHA256, artifact.Name)
+	}
+
+	if err := utils.WriteSHA256Sums(artifacts, sha256SumsPth); err != nil {
+		return fmt.Errorf("failed to write SHA256SUMS: %s", err)
+	}
+	if err := utils.ExportOutputFile(sha256SumsPth, sha256SumsPth, bitriseSHA256SumsPathEnvKey); err != nil {
+		return fmt.Errorf("failed to export %s: %s", bitriseSHA256SumsPathEnvKey, err)
+	}
+
+	if err := fileutil.WriteJSONToFile(manifestPth, artifactManifest{Artifacts: artifacts}); err != nil {
+		return fmt.Errorf("failed to write artifact manifest: %s", err)
+	}
+	if err := utils.ExportOutputFile(manifestPth, manifestPth, bitriseArtifactManifestPathEnvKey); err != nil {
+		return fmt.Errorf("failed to export %s: %s", bitriseArtifactManifestPathEnvKey, err)
+	}
+
+	log.Donef("The SHA256SUMS path is now available in the Environment Variable: %s (value: %s)", bitriseSHA256SumsPathEnvKey, sha256SumsPth)
+	log.Donef("The artifact manifest path is now available in the Environment Variable: %s (value: %s)", bitriseArtifactManifestPathEnvKey, manifestPth)
+
+	return nil
+}
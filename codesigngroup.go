@@ -8,12 +8,12 @@ import (
 	"github.com/bitrise-io/go-xcode/export"
 	"github.com/bitrise-io/go-xcode/exportoptions"
 	"github.com/bitrise-io/go-xcode/profileutil"
-	"github.com/bitrise-io/go-xcode/xcarchive"
 )
 
-func generateMacExportOptionsPlist(archive xcarchive.MacosArchive, exportMethod exportoptions.Method, teamID string) (exportoptions.ExportOptions, error) {
-	var macCodeSignGroup *export.MacCodeSignGroup
-	exportProfileMapping := map[string]string{}
+// resolveMacCodeSignGroups returns the code sign groups matching the archive, the installed certificates and profiles
+// in the order of preference. No group is returned if the archive was generated without provisioning profile.
+func resolveMacCodeSignGroups(archive macosArchive, exportMethod exportoptions.Method, teamID string) ([]export.MacCodeSignGroup, error) {
+	var macCodeSignGroups []export.MacCodeSignGroup
 
 	// We do not need provisioning profile for the export if the app in the generated XcArchive doesn't
 	// contain embedded provisioning profile.
@@ -71,23 +71,29 @@ func generateMacExportOptionsPlist(archive xcarchive.MacosArchive, exportMethod
 			}
 		}
 
-		macCodeSignGroup, err = matchingMacCodeSignGroups(archive, validCertificates, validInstallerCertificates, installedProfiles, exportMethod, teamID)
+		macCodeSignGroups, err = matchingMacCodeSignGroups(archive, validCertificates, validInstallerCertificates, installedProfiles, exportMethod, teamID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to find code sign groups for the project: %s", err)
 		}
-
-		if macCodeSignGroup != nil {
-			for bundleID, profileInfo := range macCodeSignGroup.BundleIDProfileMap() {
-				exportProfileMapping[bundleID] = profileInfo.Name
-			}
-		}
-
 	} else {
 		log.Printf("Archive was generated without provisioning profile.")
 		log.Printf("Export the application using automatic signing...")
 		fmt.Println()
 	}
 
+	return macCodeSignGroups, nil
+}
+
+// generateMacExportOptionsPlist creates the export options signing with the given code sign group,
+// or using automatic signing if the group is nil.
+func generateMacExportOptionsPlist(macCodeSignGroup *export.MacCodeSignGroup, exportMethod exportoptions.Method) exportoptions.ExportOptions {
+	exportProfileMapping := map[string]string{}
+	if macCodeSignGroup != nil {
+		for bundleID, profileInfo := range macCodeSignGroup.BundleIDProfileMap() {
+			exportProfileMapping[bundleID] = profileInfo.Name
+		}
+	}
+
 	var exportOpts exportoptions.ExportOptions
 	if exportMethod == exportoptions.MethodAppStore {
 		options := exportoptions.NewAppStoreOptions()
@@ -110,12 +116,12 @@ func generateMacExportOptionsPlist(archive xcarchive.MacosArchive, exportMethod
 		exportOpts = options
 	}
 
-	return exportOpts, nil
+	return exportOpts
 }
 
-func matchingMacCodeSignGroups(archive xcarchive.MacosArchive, installedCertificates []certificateutil.CertificateInfoModel,
+func matchingMacCodeSignGroups(archive macosArchive, installedCertificates []certificateutil.CertificateInfoModel,
 	installedInstallerCertificates []certificateutil.CertificateInfoModel, installedProfiles []profileutil.ProvisioningProfileInfoModel,
-	exportMethod exportoptions.Method, forceTeamID string) (*export.MacCodeSignGroup, error) {
+	exportMethod exportoptions.Method, forceTeamID string) ([]export.MacCodeSignGroup, error) {
 	if archive.Application.ProvisioningProfile == nil {
 		return nil, fmt.Errorf("precondition false, provisioning profile expected in the archive")
 	}
@@ -195,5 +201,49 @@ func matchingMacCodeSignGroups(archive xcarchive.MacosArchive, installedCertific
 	} else if len(macCodeSignGroups) > 1 {
 		log.Warnf("Multiple matching  codesiging groups found for the project, using first...")
 	}
-	return &(macCodeSignGroups[0]), nil
+	return macCodeSignGroups, nil
+}
+
+// macExportOptionsContent generates and prints the export options signing with the i-th code sign group,
+// or using automatic signing if there is no such group.
+func macExportOptionsContent(macCodeSignGroups []export.MacCodeSignGroup, i int, exportMethod exportoptions.Method) (string, error) {
+	var macCodeSignGroup *export.MacCodeSignGroup
+	if i < len(macCodeSignGroups) {
+		macCodeSignGroup = &macCodeSignGroups[i]
+	}
+
+	content, err := generateMacExportOptionsPlist(macCodeSignGroup, exportMethod).String()
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("generated export options content:")
+	fmt.Println()
+	log.Printf("%s", content)
+
+	return content, nil
+}
+
+// codeSignGroupReport describes the code sign group an export used.
+type codeSignGroupReport struct {
+	// Index is the 1-based rank of the group among the matching groups.
+	Index                int               `json:"index"`
+	Certificate          string            `json:"certificate"`
+	InstallerCertificate string            `json:"installer_certificate,omitempty"`
+	Profiles             map[string]string `json:"profiles,omitempty"`
+}
+
+func newCodeSignGroupReport(index int, group export.MacCodeSignGroup) codeSignGroupReport {
+	report := codeSignGroupReport{
+		Index:       index + 1,
+		Certificate: group.Certificate().CommonName,
+		Profiles:    map[string]string{},
+	}
+	if installerCertificate := group.InstallerCertificate(); installerCertificate != nil {
+		report.InstallerCertificate = installerCertificate.CommonName
+	}
+	for bundleID, profile := range group.BundleIDProfileMap() {
+		report.Profiles[bundleID] = profile.Name
+	}
+	return report
 }
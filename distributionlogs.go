@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+const (
+	maxDistributionLogErrors      = 10
+	maxDistributionLogEntryLength = 500
+)
+
+// distributionLogNames lists the logs of the xcdistributionlogs bundle from the most to the least condensed one.
+var distributionLogNames = []string{"IDEDistribution.critical.log", "IDEDistribution.standard.log", "IDEDistribution.verbose.log"}
+
+var (
+	// distributionLogEntryRegexp matches the first line of a log entry: 2019-08-20 15:19:02 +0000  [MT] message
+	distributionLogEntryRegexp = regexp.MustCompile(`^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}(?:\.\d+)? [+-]\d{4}\s+\[[^\]]*\]\s*(.*)$`)
+	distributionStepRegexp     = regexp.MustCompile(`Step failed: <?(IDEDistribution\w*Step)`)
+	distributionErrorRegexp    = regexp.MustCompile(`(?i)\berror\b|Step failed|failed:`)
+)
+
+// distributionLogSummary is the condensed content of the xcdistributionlogs bundle.
+type distributionLogSummary struct {
+	FailedStep string   `json:"failed_step,omitempty"`
+	Errors     []string `json:"errors,omitempty"`
+}
+
+// readDistributionLogs extracts the failing distribution step and the error level entries of the xcdistributionlogs bundle.
+// Every entry of the critical log is an error, the other logs are searched for error entries only if the critical log has none.
+func readDistributionLogs(logsDirPth string) (distributionLogSummary, error) {
+	summary := distributionLogSummary{}
+	seen := map[string]bool{}
+
+	for _, name := range distributionLogNames {
+		pth := filepath.Join(logsDirPth, name)
+		if exist, err := pathutil.IsPathExists(pth); err != nil {
+			return distributionLogSummary{}, fmt.Errorf("failed to check if %s exists: %s", name, err)
+		} else if !exist {
+			log.Debugf("%s not found in the xcdistributionlogs", name)
+			continue
+		}
+
+		entries, err := readDistributionLogEntries(pth)
+		if err != nil {
+			return distributionLogSummary{}, fmt.Errorf("failed to read %s: %s", name, err)
+		}
+
+		critical := name == distributionLogNames[0]
+		for _, entry := range entries {
+			if summary.FailedStep == "" {
+				if match := distributionStepRegexp.FindStringSubmatch(entry); len(match) == 2 {
+					summary.FailedStep = match[1]
+				}
+			}
+
+			if !critical && !distributionErrorRegexp.MatchString(entry) {
+				continue
+			}
+			if seen[entry] || len(summary.Errors) >= maxDistributionLogErrors {
+				continue
+			}
+			seen[entry] = true
+			summary.Errors = append(summary.Errors, entry)
+		}
+
+		if len(summary.Errors) > 0 && summary.FailedStep != "" {
+			break
+		}
+	}
+
+	return summary, nil
+}
+
+// readDistributionLogEntries returns the messages of the log, the continuation lines are joined to their entry.
+func readDistributionLogEntries(pth string) ([]string, error) {
+	f, err := os.Open(pth)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Warnf("Failed to close %s, error: %s", pth, err)
+		}
+	}()
+
+	var entries []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if match := distributionLogEntryRegexp.FindStringSubmatch(line); len(match) == 2 {
+			entries = append(entries, match[1])
+		} else if len(entries) > 0 && strings.TrimSpace(line) != "" {
+			entries[len(entries)-1] += " " + strings.TrimSpace(line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, entry := range entries {
+		entries[i] = truncateLogEntry(entry, maxDistributionLogEntryLength)
+	}
+
+	return entries, nil
+}
+
+// truncateLogEntry cuts the entry to at most maxLength bytes on a UTF-8 character boundary, and marks the cut with an ellipsis.
+func truncateLogEntry(entry string, maxLength int) string {
+	if len(entry) <= maxLength {
+		return entry
+	}
+
+	cut := maxLength
+	for cut > 0 && !utf8.RuneStart(entry[cut]) {
+		cut--
+	}
+	return entry[:cut] + "..."
+}
+
+// printDistributionLogSummary logs the failing distribution step and the errors of the xcdistributionlogs.
+func printDistributionLogSummary(summary distributionLogSummary) {
+	if summary.FailedStep == "" && len(summary.Errors) == 0 {
+		log.Printf("No error found in the xcdistributionlogs")
+		return
+	}
+
+	if summary.FailedStep != "" {
+		log.Errorf("Failed distribution step: %s", summary.FailedStep)
+	}
+	for _, entry := range summary.Errors {
+		log.Printf("- %s", entry)
+	}
+}
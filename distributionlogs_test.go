@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+const testStepFailedEntry = `Step failed: <IDEDistributionSigningAssetsStep: 0x600003a8c000>: Error Domain=IDEProfileLocatorErrorDomain Code=1 "No profiles for 'io.bitrise.Sample' were found"`
+
+func TestReadDistributionLogEntries(t *testing.T) {
+	entries, err := readDistributionLogEntries(filepath.Join("testdata", "standard.xcdistributionlogs", "IDEDistribution.standard.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"Running /Applications/Xcode.app/Contents/Developer/usr/bin/xcodebuild '-exportArchive' '-archivePath' '/tmp/Sample.xcarchive' '-exportPath' '/tmp/__export__' '-exportOptionsPlist' '/tmp/export_options.plist'",
+		`Options: { method = "developer-id"; teamID = ABCDE12345; }`,
+		testStepFailedEntry,
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("got %q, want %q", entries, want)
+	}
+}
+
+func TestReadDistributionLogEntriesTruncatesLongEntries(t *testing.T) {
+	pth := filepath.Join(t.TempDir(), "IDEDistribution.critical.log")
+	message := strings.Repeat("a", maxDistributionLogEntryLength-1) + "é and more"
+	if err := os.WriteFile(pth, []byte("2026-10-19 10:00:03 +0000  [MT] "+message+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := readDistributionLogEntries(pth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Repeat("a", maxDistributionLogEntryLength-1) + "..."; len(entries) != 1 || entries[0] != want {
+		t.Errorf("got %q, want %q", entries, want)
+	}
+}
+
+func TestTruncateLogEntry(t *testing.T) {
+	tests := []struct {
+		entry     string
+		maxLength int
+		want      string
+	}{
+		{entry: "short", maxLength: 10, want: "short"},
+		{entry: "exactly10!", maxLength: 10, want: "exactly10!"},
+		{entry: "ascii entry", maxLength: 5, want: "ascii..."},
+		{entry: "aé", maxLength: 2, want: "a..."},
+		{entry: "aéb", maxLength: 3, want: "aé..."},
+		{entry: "日本語", maxLength: 4, want: "日..."},
+		{entry: "日本語", maxLength: 2, want: "..."},
+	}
+
+	for _, tt := range tests {
+		got := truncateLogEntry(tt.entry, tt.maxLength)
+		if got != tt.want {
+			t.Errorf("truncateLogEntry(%q, %d) = %q, want %q", tt.entry, tt.maxLength, got, tt.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("truncateLogEntry(%q, %d) = %q, invalid UTF-8", tt.entry, tt.maxLength, got)
+		}
+	}
+}
+
+func TestReadDistributionLogs(t *testing.T) {
+	tests := []struct {
+		name string
+		want distributionLogSummary
+	}{
+		{
+			// every entry of the critical log is an error, duplicates are listed once
+			name: "critical.xcdistributionlogs",
+			want: distributionLogSummary{
+				FailedStep: "IDEDistributionSigningAssetsStep",
+				Errors: []string{
+					testStepFailedEntry + ` UserInfo={IDEDistributionIssueSeverity=3, NSLocalizedDescription=No profiles for 'io.bitrise.Sample' were found, NSLocalizedRecoverySuggestion=Xcode couldn't find any Developer ID provisioning profiles matching 'io.bitrise.Sample'.}`,
+				},
+			},
+		},
+		{
+			// without critical log only the error entries of the standard log are listed
+			name: "standard.xcdistributionlogs",
+			want: distributionLogSummary{
+				FailedStep: "IDEDistributionSigningAssetsStep",
+				Errors:     []string{testStepFailedEntry},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary, err := readDistributionLogs(filepath.Join("testdata", tt.name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(summary, tt.want) {
+				t.Errorf("got %+v, want %+v", summary, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadDistributionLogsWithoutErrors(t *testing.T) {
+	summary, err := readDistributionLogs(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(summary, distributionLogSummary{}) {
+		t.Errorf("got %+v, want an empty summary", summary)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-io/go-xcode/plistutil"
+	"github.com/bitrise-steplib/steps-export-xcarchive-mac/utils"
+)
+
+const (
+	bitriseExportOptionsPlistPathEnvKey  = "BITRISE_EXPORT_OPTIONS_PLIST_PATH"
+	bitriseDistributionSummaryPathEnvKey = "BITRISE_DISTRIBUTION_SUMMARY_PATH"
+	bitrisePackagingLogPathEnvKey        = "BITRISE_PACKAGING_LOG_PATH"
+
+	distributionSummaryPlistName = "DistributionSummary.plist"
+)
+
+// xcodebuildExportFiles lists the files xcodebuild writes next to the exported app or pkg.
+var xcodebuildExportFiles = []struct {
+	name   string
+	envKey string
+}{
+	{name: "ExportOptions.plist", envKey: bitriseExportOptionsPlistPathEnvKey},
+	{name: distributionSummaryPlistName, envKey: bitriseDistributionSummaryPathEnvKey},
+	{name: "Packaging.log", envKey: bitrisePackagingLogPathEnvKey},
+}
+
+// exportXcodebuildExportFiles copies the export options, distribution summary and packaging log
+// written by xcodebuild into the deploy dir. The copies are prefixed by xcodebuild_, as the step's own
+// export_options.plist would be the same file as ExportOptions.plist on a case-insensitive file system.
+func exportXcodebuildExportFiles(exportDir string, namer artifactNamer) error {
+	for _, file := range xcodebuildExportFiles {
+		sourcePth := filepath.Join(exportDir, file.name)
+		if exist, err := pathutil.IsPathExists(sourcePth); err != nil {
+			return fmt.Errorf("failed to check if %s exists: %s", file.name, err)
+		} else if !exist {
+			log.Debugf("%s not found in the export dir", file.name)
+			continue
+		}
+
+		destinationPth := namer.auxiliaryPath("xcodebuild_" + file.name)
+		if err := utils.ExportOutputFile(sourcePth, destinationPth, file.envKey); err != nil {
+			return fmt.Errorf("failed to export %s: %s", file.envKey, err)
+		}
+
+		log.Donef("The %s path is now available in the Environment Variable: %s (value: %s)", file.name, file.envKey, destinationPth)
+	}
+
+	return nil
+}
+
+// printDistributionSummary logs the signing actually applied by xcodebuild to each bundle.
+func printDistributionSummary(pth string) error {
+	summary, err := plistutil.NewPlistDataFromFile(pth)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %s", distributionSummaryPlistName, err)
+	}
+
+	var artifactNames []string
+	for name := range summary {
+		artifactNames = append(artifactNames, name)
+	}
+	sort.Strings(artifactNames)
+
+	for _, artifactName := range artifactNames {
+		bundles, found := summary.GetMapStringInterfaceArray(artifactName)
+		if !found {
+			continue
+		}
+
+		log.Printf("%s:", artifactName)
+		for _, bundle := range bundles {
+			printDistributionSummaryBundle(bundle, "")
+		}
+	}
+
+	return nil
+}
+
+func printDistributionSummaryBundle(bundle plistutil.PlistData, indent string) {
+	name, _ := bundle.GetString("name")
+	log.Printf("%s- %s", indent, name)
+
+	if certificate, found := bundle.GetMapStringInterface("certificate"); found {
+		certificateType, _ := certificate.GetString("type")
+		sha1, _ := certificate.GetString("SHA1")
+		expiry, _ := certificate.GetTime("dateExpires")
+		log.Printf("%s  certificate: %s (SHA1: %s, expires: %s)", indent, certificateType, sha1, expiry)
+	}
+
+	if profile, found := bundle.GetMapStringInterface("profile"); found {
+		profileName, _ := profile.GetString("name")
+		uuid, _ := profile.GetString("UUID")
+		expiry, _ := profile.GetTime("dateExpires")
+		log.Printf("%s  profile: %s (UUID: %s, expires: %s)", indent, profileName, uuid, expiry)
+	}
+
+	if team, found := bundle.GetMapStringInterface("team"); found {
+		teamID, _ := team.GetString("id")
+		teamName, _ := team.GetString("name")
+		log.Printf("%s  team: %s (%s)", indent, teamName, teamID)
+	}
+
+	if entitlements, found := bundle.GetMapStringInterface("entitlements"); found {
+		var keys []string
+		for key := range entitlements {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		log.Printf("%s  entitlements: %s", indent, strings.Join(keys, ", "))
+	}
+
+	if embeddedBinaries, found := bundle.GetMapStringInterfaceArray("embeddedBinaries"); found {
+		for _, embeddedBinary := range embeddedBinaries {
+			printDistributionSummaryBundle(embeddedBinary, indent+"  ")
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-xcode/xcarchive"
+	"github.com/bitrise-steplib/steps-export-xcarchive-mac/utils"
+)
+
+const (
+	bitriseDSYMPathEnvKey     = "BITRISE_DSYM_PATH"
+	bitriseDSYMPathListEnvKey = "BITRISE_DSYM_PATH_LIST"
+)
+
+// productBundleExts are the extensions of the bundles embedded in a product, which are products on their own:
+// app extensions, XPC services, system extensions and helper apps.
+var productBundleExts = map[string]bool{
+	".app":             true,
+	".appex":           true,
+	".xpc":             true,
+	".systemextension": true,
+}
+
+// productDSYMNames returns the names of the dSYMs belonging to the products of the archive:
+// the products themselves (app, command-line tool or framework) and the bundles embedded in them (see productBundleExts).
+func productDSYMNames(productPths []string) (map[string]bool, error) {
+	names := map[string]bool{}
+	for _, productPth := range productPths {
+		names[filepath.Base(productPth)+".dSYM"] = true
+
+		if err := filepath.Walk(productPth, func(pth string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if pth != productPth && info.IsDir() && productBundleExts[filepath.Ext(pth)] {
+				names[filepath.Base(pth)+".dSYM"] = true
+			}
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("failed to walk %s: %s", productPth, err)
+		}
+	}
+	return names, nil
+}
+
+// findDSYMs returns every dSYM of the archive.
+// Only the archive path is used, so it works with archives without application too.
+func findDSYMs(archivePath string) ([]string, error) {
+	appDSYMs, otherDSYMs, err := xcarchive.MacosArchive{Path: archivePath}.FindDSYMs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find dSYMs in the archive: %s", err)
+	}
+
+	dsyms := append(append([]string{}, appDSYMs...), otherDSYMs...)
+	sort.Strings(dsyms)
+	return dsyms, nil
+}
+
+// collectDSYMs returns the dSYMs of the products and, if exportAllDSYMs is set, the framework and library dSYMs of the archive.
+// The dSYMs are classified by the products of the archive (see productDSYMNames), not by their extension,
+// so the dSYMs of app extensions, XPC services and command-line tools are product dSYMs too.
+func collectDSYMs(archivePath string, productPths []string, exportAllDSYMs bool) ([]string, error) {
+	dsyms, err := findDSYMs(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	productDSYMs, err := productDSYMNames(productPths)
+	if err != nil {
+		return nil, err
+	}
+
+	var collected, frameworkDSYMs []string
+	for _, dsym := range dsyms {
+		if productDSYMs[filepath.Base(dsym)] {
+			collected = append(collected, dsym)
+		} else {
+			frameworkDSYMs = append(frameworkDSYMs, dsym)
+		}
+	}
+
+	log.Debugf("Product dSYMs: %s", collected)
+	log.Debugf("Framework dSYMs: %s", frameworkDSYMs)
+
+	if exportAllDSYMs {
+		collected = append(collected, frameworkDSYMs...)
+	}
+
+	return collected, nil
+}
+
+// exportDSYMs zips every dSYM of the archive into the deploy dir one by one, and all of them together into the main dSYM zip.
+func exportDSYMs(archivePath string, productPths []string, namer artifactNamer, exportAllDSYMs bool) error {
+	dsyms, err := collectDSYMs(archivePath, productPths, exportAllDSYMs)
+	if err != nil {
+		return err
+	}
+
+	if len(dsyms) == 0 {
+		log.Warnf("No dSYM found in the archive")
+		return nil
+	}
+
+	var dsymZipPths []string
+	for _, dsym := range dsyms {
+		dsymZipPth := namer.auxiliaryPath(filepath.Base(dsym) + ".zip")
+		if err := utils.CopyDirsAsZip([]string{dsym}, dsymZipPth); err != nil {
+			return fmt.Errorf("failed to zip dSYM (%s): %s", dsym, err)
+		}
+
+		log.Printf("dSYM exported: %s", dsymZipPth)
+		dsymZipPths = append(dsymZipPths, dsymZipPth)
+	}
+
+	dsymsZipPth := namer.artifactPath(".dSYM.zip")
+	if err := utils.ExportOutputList(dsymZipPths, bitriseDSYMPathListEnvKey); err != nil {
+		return fmt.Errorf("failed to export %s: %s", bitriseDSYMPathListEnvKey, err)
+	}
+
+	if err := utils.ExportOutputDirsAsZip(dsyms, dsymsZipPth, bitriseDSYMPathEnvKey); err != nil {
+		return fmt.Errorf("failed to export %s: %s", bitriseDSYMPathEnvKey, err)
+	}
+
+	log.Donef("The dSYM path is now available in the Environment Variable: %s (value: %s)", bitriseDSYMPathEnvKey, dsymsZipPth)
+	log.Donef("The dSYM path list is now available in the Environment Variable: %s", bitriseDSYMPathListEnvKey)
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func createTestDirs(t *testing.T, pths ...string) {
+	t.Helper()
+
+	for _, pth := range pths {
+		if err := os.MkdirAll(pth, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCollectDSYMs(t *testing.T) {
+	archivePth := filepath.Join(t.TempDir(), "Test.xcarchive")
+	appPth := filepath.Join(archivePth, "Products", "Applications", "Test.app")
+	createTestDirs(t,
+		filepath.Join(appPth, "Contents", "PlugIns", "Widget.appex"),
+		filepath.Join(appPth, "Contents", "XPCServices", "Helper.xpc"),
+		filepath.Join(appPth, "Contents", "Frameworks", "Test.framework"),
+	)
+
+	dsymsPth := filepath.Join(archivePth, "dSYMs")
+	for _, name := range []string{"Test.app.dSYM", "Widget.appex.dSYM", "Helper.xpc.dSYM", "Test.framework.dSYM", "libTest.dylib.dSYM"} {
+		createTestDirs(t, filepath.Join(dsymsPth, name))
+	}
+
+	tests := []struct {
+		name           string
+		exportAllDSYMs bool
+		want           []string
+	}{
+		{
+			name:           "product dSYMs",
+			exportAllDSYMs: false,
+			want:           []string{"Helper.xpc.dSYM", "Test.app.dSYM", "Widget.appex.dSYM"},
+		},
+		{
+			name:           "all dSYMs",
+			exportAllDSYMs: true,
+			want:           []string{"Helper.xpc.dSYM", "Test.app.dSYM", "Widget.appex.dSYM", "Test.framework.dSYM", "libTest.dylib.dSYM"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsyms, err := collectDSYMs(archivePth, []string{appPth}, tt.exportAllDSYMs)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, dsym := range dsyms {
+				got = append(got, filepath.Base(dsym))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectDSYMsOfCommandLineTool(t *testing.T) {
+	archivePth := filepath.Join(t.TempDir(), "Test.xcarchive")
+	binDirPth := filepath.Join(archivePth, "Products", "usr", "local", "bin")
+	createTestDirs(t, binDirPth, filepath.Join(archivePth, "dSYMs", "tool.dSYM"), filepath.Join(archivePth, "dSYMs", "libTest.dylib.dSYM"))
+
+	toolPth := filepath.Join(binDirPth, "tool")
+	if err := os.WriteFile(toolPth, []byte("tool"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	dsyms, err := collectDSYMs(archivePth, []string{toolPth}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dsyms) != 1 || filepath.Base(dsyms[0]) != "tool.dSYM" {
+		t.Errorf("got %v, want the tool.dSYM only", dsyms)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-steplib/steps-export-xcarchive-mac/utils"
+)
+
+const bitriseDSYMUUIDsPathEnvKey = "BITRISE_DSYM_UUIDS_PATH"
+
+// binaryUUIDs describes the slices of an executable or a dSYM DWARF binary.
+type binaryUUIDs struct {
+	Path   string       `json:"path"`
+	Slices []machOSlice `json:"slices"`
+}
+
+// unmatchedBinary is an executable slice which has no dSYM with matching UUID.
+type unmatchedBinary struct {
+	Path string `json:"path"`
+	Arch string `json:"arch"`
+	UUID string `json:"uuid"`
+}
+
+// dsymUUIDReport is the content of the dsym_uuids.json file.
+type dsymUUIDReport struct {
+	Binaries  []binaryUUIDs     `json:"binaries"`
+	DSYMs     []binaryUUIDs     `json:"dsyms"`
+	Unmatched []unmatchedBinary `json:"unmatched"`
+}
+
+// collectExecutableUUIDs walks the bundle (or single binary) and returns the UUIDs of every Mach-O file in it.
+// Symlinks are not followed, so versioned framework binaries are listed only once.
+func collectExecutableUUIDs(bundlePth string) ([]binaryUUIDs, error) {
+	var binaries []binaryUUIDs
+	if err := filepath.Walk(bundlePth, func(pth string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		if machO, err := isMachO(pth); err != nil {
+			return err
+		} else if !machO {
+			return nil
+		}
+
+		slices, err := machOSlices(pth)
+		if err != nil {
+			log.Warnf("Skipping binary: %s", err)
+			return nil
+		}
+
+		relPth, err := filepath.Rel(filepath.Dir(bundlePth), pth)
+		if err != nil {
+			return err
+		}
+
+		binaries = append(binaries, binaryUUIDs{Path: relPth, Slices: slices})
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %s", bundlePth, err)
+	}
+
+	return binaries, nil
+}
+
+// collectDSYMUUIDs returns the UUIDs of the DWARF binaries of the given dSYMs.
+func collectDSYMUUIDs(dsyms []string) ([]binaryUUIDs, error) {
+	var binaries []binaryUUIDs
+	for _, dsym := range dsyms {
+		pattern := filepath.Join(pathutil.EscapeGlobPath(dsym), "Contents/Resources/DWARF/*")
+		pths, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search for DWARF binaries using pattern: %s, error: %s", pattern, err)
+		}
+
+		for _, pth := range pths {
+			slices, err := machOSlices(pth)
+			if err != nil {
+				return nil, err
+			}
+
+			relPth, err := filepath.Rel(filepath.Dir(dsym), pth)
+			if err != nil {
+				return nil, err
+			}
+
+			binaries = append(binaries, binaryUUIDs{Path: relPth, Slices: slices})
+		}
+	}
+
+	return binaries, nil
+}
+
+func newDSYMUUIDReport(executables, dsyms []binaryUUIDs) dsymUUIDReport {
+	dsymUUIDs := map[string]bool{}
+	for _, dsym := range dsyms {
+		for _, slice := range dsym.Slices {
+			dsymUUIDs[slice.UUID] = true
+		}
+	}
+
+	unmatched := []unmatchedBinary{}
+	for _, executable := range executables {
+		for _, slice := range executable.Slices {
+			if slice.UUID != "" && !dsymUUIDs[slice.UUID] {
+				unmatched = append(unmatched, unmatchedBinary{Path: executable.Path, Arch: slice.Arch, UUID: slice.UUID})
+			}
+		}
+	}
+	sort.Slice(unmatched, func(i, j int) bool {
+		if unmatched[i].Path != unmatched[j].Path {
+			return unmatched[i].Path < unmatched[j].Path
+		}
+		return unmatched[i].Arch < unmatched[j].Arch
+	})
+
+	return dsymUUIDReport{
+		Binaries:  executables,
+		DSYMs:     dsyms,
+		Unmatched: unmatched,
+	}
+}
+
+// checkDSYMUUIDs matches the LC_UUIDs of the products' executables with the dSYMs' DWARF binaries,
+// writes the result into the given report file and returns the executable slices without dSYM.
+func checkDSYMUUIDs(productPths []string, dsyms []string, reportPth string) ([]unmatchedBinary, error) {
+	var executables []binaryUUIDs
+	for _, productPth := range productPths {
+		productExecutables, err := collectExecutableUUIDs(productPth)
+		if err != nil {
+			return nil, err
+		}
+		executables = append(executables, productExecutables...)
+	}
+
+	dsymBinaries, err := collectDSYMUUIDs(dsyms)
+	if err != nil {
+		return nil, err
+	}
+
+	report := newDSYMUUIDReport(executables, dsymBinaries)
+
+	for _, executable := range report.Binaries {
+		log.Debugf("- %s: %s", executable.Path, executable.Slices)
+	}
+
+	if err := fileutil.WriteJSONToFile(reportPth, report); err != nil {
+		return nil, fmt.Errorf("failed to write dSYM UUID report: %s", err)
+	}
+
+	if err := utils.ExportOutputFile(reportPth, reportPth, bitriseDSYMUUIDsPathEnvKey); err != nil {
+		return nil, fmt.Errorf("failed to export %s: %s", bitriseDSYMUUIDsPathEnvKey, err)
+	}
+
+	log.Donef("The dSYM UUID report path is now available in the Environment Variable: %s (value: %s)", bitriseDSYMUUIDsPathEnvKey, reportPth)
+
+	return report.Unmatched, nil
+}
+
+func unmatchedBinariesDescription(unmatched []unmatchedBinary) string {
+	var lines []string
+	for _, binary := range unmatched {
+		lines = append(lines, fmt.Sprintf("- %s (%s): %s", binary.Path, binary.Arch, binary.UUID))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func copyTestFile(t *testing.T, src, dst string) {
+	t.Helper()
+
+	content, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, content, 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewDSYMUUIDReport(t *testing.T) {
+	executables := []binaryUUIDs{
+		{Path: "Test.app/Contents/MacOS/Test", Slices: []machOSlice{
+			{Arch: "x86_64", UUID: "AAAAAAAA-BBBB-CCCC-DDDD-EEEEEEEEEEEE"},
+			{Arch: "arm64", UUID: "11111111-2222-3333-4444-555555555555"},
+		}},
+		{Path: "Test.app/Contents/Frameworks/B.framework/Versions/A/B", Slices: []machOSlice{
+			{Arch: "arm64", UUID: "22222222-2222-2222-2222-222222222222"},
+		}},
+		{Path: "Test.app/Contents/Frameworks/A.framework/Versions/A/A", Slices: []machOSlice{
+			{Arch: "x86_64", UUID: "33333333-3333-3333-3333-333333333333"},
+			{Arch: "arm64", UUID: "44444444-4444-4444-4444-444444444444"},
+		}},
+		{Path: "Test.app/Contents/Resources/stripped", Slices: []machOSlice{
+			{Arch: "arm64", UUID: ""},
+		}},
+	}
+	dsyms := []binaryUUIDs{
+		{Path: "Test.app.dSYM/Contents/Resources/DWARF/Test", Slices: []machOSlice{
+			{Arch: "x86_64", UUID: "AAAAAAAA-BBBB-CCCC-DDDD-EEEEEEEEEEEE"},
+			{Arch: "arm64", UUID: "11111111-2222-3333-4444-555555555555"},
+		}},
+		{Path: "A.framework.dSYM/Contents/Resources/DWARF/A", Slices: []machOSlice{
+			{Arch: "x86_64", UUID: "33333333-3333-3333-3333-333333333333"},
+		}},
+	}
+
+	report := newDSYMUUIDReport(executables, dsyms)
+
+	want := []unmatchedBinary{
+		{Path: "Test.app/Contents/Frameworks/A.framework/Versions/A/A", Arch: "arm64", UUID: "44444444-4444-4444-4444-444444444444"},
+		{Path: "Test.app/Contents/Frameworks/B.framework/Versions/A/B", Arch: "arm64", UUID: "22222222-2222-2222-2222-222222222222"},
+	}
+	if !reflect.DeepEqual(report.Unmatched, want) {
+		t.Errorf("Unmatched = %v, want %v", report.Unmatched, want)
+	}
+	if !reflect.DeepEqual(report.Binaries, executables) || !reflect.DeepEqual(report.DSYMs, dsyms) {
+		t.Errorf("the report does not list the given binaries and dSYMs")
+	}
+
+	if got := newDSYMUUIDReport(executables[:1], dsyms).Unmatched; got == nil || len(got) != 0 {
+		t.Errorf("Unmatched = %#v, want an empty list", got)
+	}
+}
+
+func TestCollectUUIDs(t *testing.T) {
+	dir := t.TempDir()
+	appPth := filepath.Join(dir, "Test.app")
+	copyTestFile(t, "testdata/macho/universal", filepath.Join(appPth, "Contents", "MacOS", "Test"))
+	copyTestFile(t, "testdata/macho/arm64", filepath.Join(appPth, "Contents", "Frameworks", "Test.framework", "Versions", "A", "Test"))
+	if err := os.WriteFile(filepath.Join(appPth, "Contents", "Info.plist"), []byte("<plist/>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("Versions/A/Test", filepath.Join(appPth, "Contents", "Frameworks", "Test.framework", "Test")); err != nil {
+		t.Fatal(err)
+	}
+
+	dsymPth := filepath.Join(dir, "Test.app.dSYM")
+	copyTestFile(t, "testdata/macho/universal", filepath.Join(dsymPth, "Contents", "Resources", "DWARF", "Test"))
+
+	executables, err := collectExecutableUUIDs(appPth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var executablePths []string
+	for _, executable := range executables {
+		executablePths = append(executablePths, executable.Path)
+	}
+	wantPths := []string{"Test.app/Contents/Frameworks/Test.framework/Versions/A/Test", "Test.app/Contents/MacOS/Test"}
+	if !reflect.DeepEqual(executablePths, wantPths) {
+		t.Errorf("executables = %v, want %v", executablePths, wantPths)
+	}
+
+	dsymBinaries, err := collectDSYMUUIDs([]string{dsymPth})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dsymBinaries) != 1 || dsymBinaries[0].Path != "Test.app.dSYM/Contents/Resources/DWARF/Test" {
+		t.Fatalf("dSYM binaries = %v", dsymBinaries)
+	}
+
+	unmatched := newDSYMUUIDReport(executables, dsymBinaries).Unmatched
+	want := []unmatchedBinary{{Path: "Test.app/Contents/Frameworks/Test.framework/Versions/A/Test", Arch: "arm64", UUID: "11111111-2222-3333-4444-555555555555"}}
+	if !reflect.DeepEqual(unmatched, want) {
+		t.Errorf("Unmatched = %v, want %v", unmatched, want)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// envSanitizer removes the listed variables from the environment of the xcodebuild processes,
+// the step's own environment is kept intact for the other tools it runs.
+type envSanitizer struct {
+	keys     map[string]bool
+	prefixes []string
+}
+
+// parseEnvSanitizer parses the newline separated list of variable names, an entry ending with * matches every variable with the prefix.
+func parseEnvSanitizer(list string) (envSanitizer, error) {
+	sanitizer := envSanitizer{keys: map[string]bool{}}
+	for _, entry := range strings.Split(list, "\n") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name := strings.TrimSuffix(entry, "*")
+		if name == "" || strings.ContainsAny(name, "=* \t") {
+			return envSanitizer{}, fmt.Errorf("invalid environment variable name: %s", entry)
+		}
+
+		if name != entry {
+			sanitizer.prefixes = append(sanitizer.prefixes, name)
+		} else {
+			sanitizer.keys[name] = true
+		}
+	}
+	return sanitizer, nil
+}
+
+func (s envSanitizer) matches(key string) bool {
+	if s.keys[key] {
+		return true
+	}
+	for _, prefix := range s.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// environ returns the environment without the matching variables, and the names of the removed ones.
+func (s envSanitizer) environ(environ []string) ([]string, []string) {
+	var env, removed []string
+	for _, keyValue := range environ {
+		key := strings.SplitN(keyValue, "=", 2)[0]
+		if s.matches(key) {
+			removed = append(removed, key)
+			continue
+		}
+		env = append(env, keyValue)
+	}
+
+	sort.Strings(removed)
+	return env, removed
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-io/go-xcode/xcodebuild"
+)
+
+const (
+	// maxExportRetries is the upper limit of the export_retries input.
+	maxExportRetries = 10
+	// maxExportRetryDelay is the upper limit of the delay between the export retries.
+	maxExportRetryDelay = 10 * time.Minute
+)
+
+// exportAttempt is a single run of xcodebuild -exportArchive.
+type exportAttempt struct {
+	Number          int             `json:"number"`
+	StartedAt       time.Time       `json:"started_at"`
+	DurationSeconds float64         `json:"duration_seconds"`
+	Status          string          `json:"status"`
+	Failures        []exportFailure `json:"failures,omitempty"`
+	// CodeSignGroup is the 1-based rank of the code sign group the attempt used, if the export options were generated.
+	CodeSignGroup int `json:"code_sign_group,omitempty"`
+
+	// exportDir is the fresh temp dir the attempt exported into.
+	exportDir string
+	output    *xcodebuildOutput
+	err       error
+	// interrupted is set if the export was terminated by the step because of the timeout or a termination signal.
+	interrupted bool
+}
+
+// exportAttemptConfig configures the xcodebuild export attempts.
+type exportAttemptConfig struct {
+	ArchivePath       string
+	ExportOptionsPath string
+	OutputFormat      string
+	// RawLog receives the raw xcodebuild output of every attempt.
+	RawLog io.Writer
+	// Env is the environment of xcodebuild.
+	Env     []string
+	Timeout time.Duration
+	// Retries is the number of retries of the exports failing with transient errors.
+	Retries        int
+	RetryBaseDelay time.Duration
+}
+
+// runExportAttempt exports the archive into a fresh temp dir, and classifies the errors if the export fails.
+func runExportAttempt(number int, config exportAttemptConfig) (exportAttempt, error) {
+	exportDir, err := pathutil.NormalizedOSTempDirPath("__export__")
+	if err != nil {
+		return exportAttempt{}, fmt.Errorf("failed to create tmp dir: %s", err)
+	}
+
+	exportCmd := xcodebuild.NewExportCommand()
+	exportCmd.SetArchivePath(config.ArchivePath)
+	exportCmd.SetExportDir(exportDir)
+	exportCmd.SetExportOptionsPlist(config.ExportOptionsPath)
+
+	log.Donef("$ %s", exportCmd.PrintableCmd())
+	fmt.Println()
+
+	if _, err := fmt.Fprintf(config.RawLog, "=== Export attempt %d: %s ===\n", number, exportCmd.PrintableCmd()); err != nil {
+		return exportAttempt{}, fmt.Errorf("failed to write xcodebuild log: %s", err)
+	}
+
+	out := config.RawLog
+	if config.OutputFormat == xcodebuildOutputFormatRaw {
+		out = io.MultiWriter(os.Stdout, config.RawLog)
+	}
+	output := newXcodebuildOutput(out, config.OutputFormat)
+	cmd := exportCmd.Command()
+	cmd.SetStdout(output)
+	cmd.SetStderr(output)
+	cmd.SetEnvs(config.Env...)
+
+	attempt := exportAttempt{
+		Number:    number,
+		StartedAt: time.Now(),
+		Status:    "succeeded",
+		exportDir: exportDir,
+		output:    output,
+	}
+
+	attempt.err = runExportCommand(cmd.GetCmd(), config.Timeout)
+	attempt.DurationSeconds = time.Since(attempt.StartedAt).Seconds()
+	if err := output.Close(); err != nil {
+		log.Warnf("Failed to process xcodebuild output, error: %s", err)
+	}
+
+	if attempt.err != nil {
+		attempt.Status = "failed"
+		if failure, interrupted := interruptedExportFailure(attempt.err, config.Timeout); interrupted {
+			attempt.Failures = []exportFailure{failure}
+			attempt.interrupted = true
+		} else {
+			attempt.Failures = output.Failures()
+		}
+	}
+
+	return attempt, nil
+}
+
+// runExportAttempts exports the archive, and retries the export while it fails with transient errors only.
+// The attempts are numbered from firstNumber.
+func runExportAttempts(firstNumber int, config exportAttemptConfig) ([]exportAttempt, error) {
+	var attempts []exportAttempt
+	for retry := 0; ; retry++ {
+		attempt, err := runExportAttempt(firstNumber+retry, config)
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, attempt)
+
+		if !attempt.retryable(retry, config.Retries) {
+			return attempts, nil
+		}
+
+		log.Warnf("Export failed with transient error(s):")
+		for _, failure := range attempt.Failures {
+			log.Printf("- [%s] %s", failure.Category, failure.Message)
+		}
+
+		delay := exportRetryDelay(config.RetryBaseDelay, retry+1)
+		fmt.Println()
+		log.Warnf("Retrying the export in %s (retry %d/%d)...", delay, retry+1, config.Retries)
+		if err := waitForRetry(delay); err != nil {
+			// the cancelled attempt is handled as an interrupted one, so its export dir is removed by the caller
+			attempt.err = err
+			attempt.Failures = append(attempt.Failures, exportFailure{
+				Category:    exportFailureCancelled,
+				Message:     err.Error(),
+				Explanation: "The step was cancelled while waiting for the retry of the export.",
+			})
+			attempt.interrupted = true
+			attempts[len(attempts)-1] = attempt
+			return attempts, nil
+		}
+
+		if err := os.RemoveAll(attempt.exportDir); err != nil {
+			log.Warnf("Failed to remove the export dir, error: %s", err)
+		}
+	}
+}
+
+// retryable returns true if the attempt failed with transient errors only, and the number of retries allows one more.
+func (a exportAttempt) retryable(retry, retries int) bool {
+	return a.err != nil && retry < retries && a.transient()
+}
+
+// transient returns true if every error of the failed attempt is transient, so that a retry might succeed.
+func (a exportAttempt) transient() bool {
+	if a.err == nil || a.interrupted || len(a.Failures) == 0 {
+		return false
+	}
+	for _, failure := range a.Failures {
+		if !failure.Transient {
+			return false
+		}
+	}
+	return true
+}
+
+// exportRetryDelay returns the delay before the given retry (1-based), doubling the base delay on every retry
+// up to maxExportRetryDelay.
+func exportRetryDelay(base time.Duration, retry int) time.Duration {
+	delay := base
+	for i := 1; i < retry && delay < maxExportRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxExportRetryDelay {
+		return maxExportRetryDelay
+	}
+	return delay
+}
+
+// fallsBackToNextGroup returns true if the export has to be retried with the next code sign group:
+// the attempt failed by itself (it was not interrupted), the fallback is enabled and there is a next group.
+func (a exportAttempt) fallsBackToNextGroup(fallback bool, groupIdx, groupCount int) bool {
+	return a.err != nil && !a.interrupted && fallback && groupIdx+1 < groupCount
+}
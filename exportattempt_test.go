@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestExportRetryDelay(t *testing.T) {
+	tests := []struct {
+		base  time.Duration
+		retry int
+		want  time.Duration
+	}{
+		{base: 10 * time.Second, retry: 1, want: 10 * time.Second},
+		{base: 10 * time.Second, retry: 2, want: 20 * time.Second},
+		{base: 10 * time.Second, retry: 4, want: 80 * time.Second},
+		{base: 10 * time.Second, retry: 7, want: 10 * time.Minute},
+		{base: 10 * time.Second, retry: 100, want: 10 * time.Minute},
+		{base: 10 * time.Minute, retry: 1, want: 10 * time.Minute},
+		{base: 0, retry: 5, want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := exportRetryDelay(tt.base, tt.retry); got != tt.want {
+			t.Errorf("exportRetryDelay(%s, %d) = %s, want %s", tt.base, tt.retry, got, tt.want)
+		}
+	}
+}
+
+func transientFailure() exportFailure {
+	return exportFailure{Category: exportFailureAppleServer, Transient: true}
+}
+
+func permanentFailure() exportFailure {
+	return exportFailure{Category: exportFailureNoProfiles}
+}
+
+func TestExportAttemptRetryable(t *testing.T) {
+	failed := errors.New("exit status 70")
+
+	tests := []struct {
+		name    string
+		attempt exportAttempt
+		retry   int
+		retries int
+		want    bool
+	}{
+		{
+			name:    "succeeded",
+			attempt: exportAttempt{},
+			retries: 3,
+			want:    false,
+		},
+		{
+			name:    "transient errors only",
+			attempt: exportAttempt{err: failed, Failures: []exportFailure{transientFailure(), transientFailure()}},
+			retries: 3,
+			want:    true,
+		},
+		{
+			name:    "transient errors, no retry left",
+			attempt: exportAttempt{err: failed, Failures: []exportFailure{transientFailure()}},
+			retry:   3,
+			retries: 3,
+			want:    false,
+		},
+		{
+			name:    "transient errors, retries disabled",
+			attempt: exportAttempt{err: failed, Failures: []exportFailure{transientFailure()}},
+			retries: 0,
+			want:    false,
+		},
+		{
+			name:    "transient and permanent errors",
+			attempt: exportAttempt{err: failed, Failures: []exportFailure{transientFailure(), permanentFailure()}},
+			retries: 3,
+			want:    false,
+		},
+		{
+			name:    "unknown error",
+			attempt: exportAttempt{err: failed},
+			retries: 3,
+			want:    false,
+		},
+		{
+			name:    "timed out",
+			attempt: exportAttempt{err: errExportTimeout, interrupted: true, Failures: []exportFailure{transientFailure()}},
+			retries: 3,
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.attempt.retryable(tt.retry, tt.retries); got != tt.want {
+				t.Errorf("retryable() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportAttemptFallsBackToNextGroup(t *testing.T) {
+	failed := exportAttempt{err: errors.New("exit status 70"), Failures: []exportFailure{permanentFailure()}}
+	cancelled := exportAttempt{err: exportCancelledError{signal: syscall.SIGTERM}, interrupted: true}
+
+	tests := []struct {
+		name       string
+		attempt    exportAttempt
+		fallback   bool
+		groupIdx   int
+		groupCount int
+		want       bool
+	}{
+		{name: "succeeded", attempt: exportAttempt{}, fallback: true, groupIdx: 0, groupCount: 2, want: false},
+		{name: "failed with a next group", attempt: failed, fallback: true, groupIdx: 0, groupCount: 2, want: true},
+		{name: "failed with the last group", attempt: failed, fallback: true, groupIdx: 1, groupCount: 2, want: false},
+		{name: "failed with fallback disabled", attempt: failed, fallback: false, groupIdx: 0, groupCount: 2, want: false},
+		{name: "failed without generated export options", attempt: failed, fallback: true, groupIdx: 0, groupCount: 0, want: false},
+		{name: "cancelled", attempt: cancelled, fallback: true, groupIdx: 0, groupCount: 2, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.attempt.fallsBackToNextGroup(tt.fallback, tt.groupIdx, tt.groupCount); got != tt.want {
+				t.Errorf("fallsBackToNextGroup() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-export-xcarchive-mac/utils"
+)
+
+const bitriseExportReportPathEnvKey = "BITRISE_EXPORT_REPORT_PATH"
+
+// exportFailureCategory groups the known xcodebuild export errors by their cause.
+type exportFailureCategory string
+
+const (
+	exportFailureInstallerCertificate exportFailureCategory = "installer_certificate_missing"
+	exportFailureSigningCertificate   exportFailureCategory = "signing_certificate_missing"
+	exportFailureEntitlement          exportFailureCategory = "profile_entitlement_missing"
+	exportFailureNoProfiles           exportFailureCategory = "profile_missing"
+	exportFailureProfileRequired      exportFailureCategory = "profile_required"
+	exportFailureKeychainInteraction  exportFailureCategory = "keychain_interaction_not_allowed"
+	exportFailureAppleServer          exportFailureCategory = "apple_server_unavailable"
+	exportFailureTimeout              exportFailureCategory = "timeout"
+	exportFailureCancelled            exportFailureCategory = "cancelled"
+	exportFailureUnknown              exportFailureCategory = "unknown"
+)
+
+// exportFailurePattern is a known xcodebuild export error with its explanation and fix.
+type exportFailurePattern struct {
+	category    exportFailureCategory
+	pattern     *regexp.Regexp
+	explanation string
+	fix         string
+	// transient errors might not occur again, the export is retried if every error of the attempt is transient.
+	transient bool
+}
+
+// exportFailureCatalog lists the known macOS export errors, the first matching pattern classifies a line,
+// so the installer certificate patterns have to precede the generic certificate ones.
+var exportFailureCatalog = []exportFailurePattern{
+	{
+		category:    exportFailureInstallerCertificate,
+		pattern:     regexp.MustCompile(`No (?:signing )?certificate (?:for team '[^']*' matching )?["']((?:3rd Party Mac Developer|Developer ID|Mac) Installer[^"']*)["']`),
+		explanation: "The installer package has to be signed by an installer certificate, which is not installed.",
+		fix:         "Upload the matching installer certificate (Developer ID Installer for developer-id, Mac Installer Distribution for app-store exports) with its private key and install it before this step.",
+	},
+	{
+		category:    exportFailureSigningCertificate,
+		pattern:     regexp.MustCompile(`No (?:signing )?certificate (?:for team '[^']*' matching )?["']([^"']+)["'](?: found)?`),
+		explanation: "The certificate required by the export method is not installed, or its private key is missing.",
+		fix:         "Upload the certificate named in the error (for example Developer ID Application) as a .p12 with its private key, install it before this step, and check that it belongs to the team of the export.",
+	},
+	{
+		category:    exportFailureEntitlement,
+		pattern:     regexp.MustCompile(`[Pp]rovisioning profile "([^"]+)" doesn't include the "?([^"]+?)"? entitlement`),
+		explanation: "The app is signed with an entitlement which is not enabled in the provisioning profile.",
+		fix:         "Enable the capability for the App ID on the Apple Developer Portal and regenerate the profile, or remove the entitlement from the target.",
+	},
+	{
+		category:    exportFailureNoProfiles,
+		pattern:     regexp.MustCompile(`No profiles for '([^']+)' were found`),
+		explanation: "No provisioning profile is installed for the bundle ID and the export method.",
+		fix:         "Create a provisioning profile of the export method's type for the bundle ID and install it before this step, or set the export method matching the installed profiles.",
+	},
+	{
+		category:    exportFailureProfileRequired,
+		pattern:     regexp.MustCompile(`"?([^"]+?)"? requires a provisioning profile`),
+		explanation: "A bundle of the app uses capabilities, which require a provisioning profile, but none is selected for it.",
+		fix:         "Install a provisioning profile for every bundle ID listed in the error, or set the provisioningProfiles of the custom export options.",
+	},
+	{
+		category:    exportFailureKeychainInteraction,
+		pattern:     regexp.MustCompile(`User interaction is not allowed|errSecInteractionNotAllowed`),
+		explanation: "codesign could not access the signing key, the keychain was locked or it prompted for access.",
+		fix:         "Unlock the keychain and allow codesign to access the signing keys (set-key-partition-list) before this step.",
+		transient:   true,
+	},
+	{
+		category:    exportFailureAppleServer,
+		pattern:     regexp.MustCompile(`(?i)Communication with Apple failed|Failed to communicate with Apple|The request timed out|The network connection was lost|Could not connect to the server|The Internet connection appears to be offline`),
+		explanation: "xcodebuild could not reach Apple's servers, which are used by automatic signing and the distribution checks.",
+		fix:         "Check the network access to Apple's servers, and retry the export.",
+		transient:   true,
+	},
+}
+
+// exportFailure is a classified xcodebuild export error.
+type exportFailure struct {
+	Category    exportFailureCategory `json:"category"`
+	Message     string                `json:"message"`
+	Explanation string                `json:"explanation"`
+	Fix         string                `json:"fix,omitempty"`
+	Transient   bool                  `json:"transient,omitempty"`
+}
+
+// exportReport is the content of the export report file.
+type exportReport struct {
+	Status       string          `json:"status"`
+	ExportMethod string          `json:"export_method"`
+	Failures     []exportFailure `json:"failures,omitempty"`
+	// Attempts lists every xcodebuild export attempt, including the retries.
+	Attempts []exportAttempt `json:"attempts,omitempty"`
+	// CodeSignGroup is the code sign group of the last attempt, if the export options were generated.
+	CodeSignGroup *codeSignGroupReport `json:"code_sign_group,omitempty"`
+	// DistributionLogs is the summary of the xcdistributionlogs, if the export failed.
+	DistributionLogs *distributionLogSummary `json:"distribution_logs,omitempty"`
+}
+
+// exportFailureClassifier matches the xcodebuild output lines against the catalog of known export errors.
+type exportFailureClassifier struct {
+	seen     map[string]bool
+	failures []exportFailure
+}
+
+func newExportFailureClassifier() *exportFailureClassifier {
+	return &exportFailureClassifier{seen: map[string]bool{}}
+}
+
+// addLine classifies the line, every known error is reported once.
+func (c *exportFailureClassifier) addLine(line string) {
+	line = strings.TrimSpace(line)
+	for _, known := range exportFailureCatalog {
+		match := known.pattern.FindString(line)
+		if match == "" {
+			continue
+		}
+
+		if !c.seen[match] {
+			c.seen[match] = true
+			c.failures = append(c.failures, exportFailure{
+				Category:    known.category,
+				Message:     line,
+				Explanation: known.explanation,
+				Fix:         known.fix,
+				Transient:   known.transient,
+			})
+		}
+		return
+	}
+}
+
+// result returns the classified errors, or the unknown category with the last error line of the output's tail if no line matched.
+func (c *exportFailureClassifier) result(tail []string) []exportFailure {
+	if len(c.failures) > 0 {
+		return c.failures
+	}
+
+	return []exportFailure{{
+		Category:    exportFailureUnknown,
+		Message:     lastErrorLine(tail),
+		Explanation: "The export failed with an error not known by the step.",
+		Fix:         "Check the xcodebuild output and the xcdistributionlogs for the reason of the error.",
+	}}
+}
+
+// interruptedExportFailure returns the failure of an export which was terminated by the step, or false if it failed by itself.
+func interruptedExportFailure(err error, timeout time.Duration) (exportFailure, bool) {
+	var cancelled exportCancelledError
+	switch {
+	case errors.Is(err, errExportTimeout):
+		return exportFailure{
+			Category:    exportFailureTimeout,
+			Message:     fmt.Sprintf("xcodebuild did not finish in %s", timeout),
+			Explanation: "The export hung, xcodebuild usually waits for a keychain access prompt or a network check in this case.",
+			Fix:         "Unlock the keychain and allow codesign to access the signing keys (set-key-partition-list) before this step, check the network access to Apple's servers, or raise the export timeout.",
+		}, true
+	case errors.As(err, &cancelled):
+		return exportFailure{
+			Category:    exportFailureCancelled,
+			Message:     cancelled.Error(),
+			Explanation: "The step was cancelled during the export.",
+		}, true
+	default:
+		return exportFailure{}, false
+	}
+}
+
+// lastErrorLine returns the last line containing an error, or the last non empty line.
+func lastErrorLine(lines []string) string {
+	for i := len(lines) - 1; i >= 0; i-- {
+		if strings.Contains(strings.ToLower(lines[i]), "error") {
+			return strings.TrimSpace(lines[i])
+		}
+	}
+	for i := len(lines) - 1; i >= 0; i-- {
+		if strings.TrimSpace(lines[i]) != "" {
+			return strings.TrimSpace(lines[i])
+		}
+	}
+	return ""
+}
+
+// printExportFailures logs the classified export errors with their explanation and fix.
+func printExportFailures(failures []exportFailure) {
+	log.Errorf("Export failed, the following error(s) were found:")
+	for _, failure := range failures {
+		fmt.Println()
+		log.Errorf("[%s] %s", failure.Category, failure.Message)
+		emitEvent(logEvent{Event: eventExportFailure, Level: levelError, Category: string(failure.Category), Message: failure.Message, Data: failure})
+		log.Printf("Explanation: %s", failure.Explanation)
+		if failure.Fix != "" {
+			log.Warnf("Fix: %s", failure.Fix)
+		}
+	}
+	fmt.Println()
+}
+
+// exportExportReport writes the export report and exports its path.
+func exportExportReport(report exportReport, pth string) error {
+	if err := fileutil.WriteJSONToFile(pth, report); err != nil {
+		return fmt.Errorf("failed to write export report: %s", err)
+	}
+	if err := utils.ExportOutputFile(pth, pth, bitriseExportReportPathEnvKey); err != nil {
+		return fmt.Errorf("failed to export %s: %s", bitriseExportReportPathEnvKey, err)
+	}
+
+	log.Donef("The export report path is now available in the Environment Variable: %s (value: %s)", bitriseExportReportPathEnvKey, pth)
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import "testing"
+
+func TestExportFailureCatalog(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		category  exportFailureCategory
+		transient bool
+	}{
+		{
+			name:     "developer id installer certificate",
+			line:     `error: exportArchive: No signing certificate "Developer ID Installer" found`,
+			category: exportFailureInstallerCertificate,
+		},
+		{
+			name:     "mac installer distribution certificate of the team",
+			line:     `error: exportArchive: No certificate for team 'ABCDE12345' matching '3rd Party Mac Developer Installer: Bitrise Ltd (ABCDE12345)' found`,
+			category: exportFailureInstallerCertificate,
+		},
+		{
+			name:     "developer id application certificate",
+			line:     `error: exportArchive: No signing certificate "Developer ID Application" found`,
+			category: exportFailureSigningCertificate,
+		},
+		{
+			name:     "apple distribution certificate of the team",
+			line:     `error: exportArchive: No certificate for team 'ABCDE12345' matching 'Apple Distribution: Bitrise Ltd (ABCDE12345)' found`,
+			category: exportFailureSigningCertificate,
+		},
+		{
+			name:     "entitlement missing from the profile",
+			line:     `error: exportArchive: Provisioning profile "Mac Team Provisioning Profile: io.bitrise.Sample" doesn't include the com.apple.developer.icloud-container-identifiers entitlement.`,
+			category: exportFailureEntitlement,
+		},
+		{
+			name:     "no profiles for the bundle id",
+			line:     `error: exportArchive: No profiles for 'io.bitrise.Sample' were found`,
+			category: exportFailureNoProfiles,
+		},
+		{
+			name:     "profile required",
+			line:     `error: exportArchive: "Sample.app" requires a provisioning profile.`,
+			category: exportFailureProfileRequired,
+		},
+		{
+			name:      "keychain interaction",
+			line:      `error: exportArchive: Sample.app: User interaction is not allowed.`,
+			category:  exportFailureKeychainInteraction,
+			transient: true,
+		},
+		{
+			name:      "apple server unavailable",
+			line:      `error: exportArchive: The request timed out.`,
+			category:  exportFailureAppleServer,
+			transient: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			classifier := newExportFailureClassifier()
+			classifier.addLine(tt.line)
+
+			failures := classifier.result(nil)
+			if len(failures) != 1 {
+				t.Fatalf("got %d failures, want 1: %+v", len(failures), failures)
+			}
+			if got := failures[0]; got.Category != tt.category || got.Transient != tt.transient || got.Message != tt.line {
+				t.Errorf("got %s (transient: %t, message: %s), want %s (transient: %t)", got.Category, got.Transient, got.Message, tt.category, tt.transient)
+			}
+		})
+	}
+}
+
+// TestExportFailureCatalogOrder checks that the installer certificate errors, which match the generic certificate pattern too,
+// are classified by the installer pattern because it precedes the generic one.
+func TestExportFailureCatalogOrder(t *testing.T) {
+	installerIdx, signingIdx := -1, -1
+	for i, known := range exportFailureCatalog {
+		switch known.category {
+		case exportFailureInstallerCertificate:
+			installerIdx = i
+		case exportFailureSigningCertificate:
+			signingIdx = i
+		}
+	}
+	if installerIdx == -1 || signingIdx == -1 {
+		t.Fatalf("catalog misses a certificate pattern, installer: %d, signing: %d", installerIdx, signingIdx)
+	}
+	if installerIdx > signingIdx {
+		t.Fatalf("installer certificate pattern (%d) has to precede the signing certificate pattern (%d)", installerIdx, signingIdx)
+	}
+
+	line := `error: exportArchive: No signing certificate "Mac Installer Distribution" found`
+	if !exportFailureCatalog[signingIdx].pattern.MatchString(line) {
+		t.Fatalf("the signing certificate pattern does not match the installer error, the order would not matter: %s", line)
+	}
+	if !exportFailureCatalog[installerIdx].pattern.MatchString(line) {
+		t.Fatalf("the installer certificate pattern does not match: %s", line)
+	}
+}
+
+func TestExportFailureClassifierUnknown(t *testing.T) {
+	classifier := newExportFailureClassifier()
+	classifier.addLine("** EXPORT FAILED **")
+
+	failures := classifier.result([]string{
+		"error: exportArchive: The operation couldn’t be completed. (IDEDistributionErrorDomain error 3.)",
+		"** EXPORT FAILED **",
+		"",
+	})
+	if len(failures) != 1 || failures[0].Category != exportFailureUnknown {
+		t.Fatalf("got %+v, want a single %s failure", failures, exportFailureUnknown)
+	}
+	if want := "error: exportArchive: The operation couldn’t be completed. (IDEDistributionErrorDomain error 3.)"; failures[0].Message != want {
+		t.Errorf("message = %s, want %s", failures[0].Message, want)
+	}
+}
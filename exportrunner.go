@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// exportTerminationGracePeriod is the time xcodebuild gets to exit after SIGTERM, before it is killed.
+const exportTerminationGracePeriod = 10 * time.Second
+
+// errExportTimeout is returned if the export did not finish within the export timeout.
+var errExportTimeout = errors.New("export timed out")
+
+// exportCancelledError is returned if the step received a termination signal during the export.
+type exportCancelledError struct {
+	signal os.Signal
+}
+
+func (e exportCancelledError) Error() string {
+	return fmt.Sprintf("export cancelled by %s", e.signal)
+}
+
+// runExportCommand runs the command in its own process group and waits for it to finish.
+// If the timeout (0 means no timeout) elapses, the process group is terminated and errExportTimeout is returned.
+// If the step receives SIGINT or SIGTERM, the signal is forwarded to the process group and exportCancelledError is returned.
+func runExportCommand(cmd *exec.Cmd, timeout time.Duration) error {
+	setProcessGroup(cmd)
+	// a descendant of xcodebuild might keep the output pipes open after the process group is terminated,
+	// Wait stops copying the output after the grace period, so that the export returns anyway
+	cmd.WaitDelay = exportTerminationGracePeriod
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	var timeoutC <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutC = timer.C
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-timeoutC:
+		log.Errorf("Export did not finish in %s, terminating xcodebuild...", timeout)
+		terminateProcessGroup(cmd, syscall.SIGTERM, done)
+		return errExportTimeout
+	case sig := <-signals:
+		log.Warnf("Received %s, cancelling the export...", sig)
+		terminateProcessGroup(cmd, sig, done)
+		return exportCancelledError{signal: sig}
+	}
+}
+
+// waitForRetry waits for the retry delay, and returns exportCancelledError if the step receives SIGINT or SIGTERM in the meantime.
+func waitForRetry(delay time.Duration) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case sig := <-signals:
+		log.Warnf("Received %s, cancelling the export retry...", sig)
+		return exportCancelledError{signal: sig}
+	}
+}
+
+// terminateProcessGroup signals the process group and kills it if it does not exit within the grace period.
+func terminateProcessGroup(cmd *exec.Cmd, sig os.Signal, done <-chan error) {
+	if err := signalProcessGroup(cmd, sig); err != nil {
+		log.Warnf("Failed to send %s to xcodebuild, error: %s", sig, err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(exportTerminationGracePeriod):
+		log.Warnf("xcodebuild did not exit in %s, killing it...", exportTerminationGracePeriod)
+		if err := signalProcessGroup(cmd, syscall.SIGKILL); err != nil {
+			log.Warnf("Failed to kill xcodebuild, error: %s", err)
+		}
+		<-done
+	}
+}
+
+// findRecentDistributionLogs returns the newest xcdistributionlogs bundle created in the temp dir since the given time,
+// used if the export was interrupted before xcodebuild printed the bundle's path.
+func findRecentDistributionLogs(since time.Time) string {
+	pattern := filepath.Join(os.TempDir(), "*.xcdistributionlogs")
+	pths, err := filepath.Glob(pattern)
+	if err != nil {
+		log.Warnf("Failed to search for xcdistributionlogs using pattern: %s, error: %s", pattern, err)
+		return ""
+	}
+
+	newest := ""
+	var newestModTime time.Time
+	for _, pth := range pths {
+		info, err := os.Stat(pth)
+		if err != nil || info.ModTime().Before(since) {
+			continue
+		}
+		if newest == "" || info.ModTime().After(newestModTime) {
+			newest, newestModTime = pth, info.ModTime()
+		}
+	}
+	return newest
+}
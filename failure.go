@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-export-xcarchive-mac/utils"
+)
+
+const (
+	bitriseFailureSummaryPathEnvKey = "BITRISE_FAILURE_SUMMARY_PATH"
+	failureSummaryFileName          = "failure_summary.json"
+)
+
+// failureClass groups the errors failing the step, every class exits with its own status code.
+type failureClass string
+
+const (
+	failureInternal failureClass = "internal"
+	failureInput    failureClass = "input"
+	failureArchive  failureClass = "archive"
+	failureSigning  failureClass = "signing"
+	failureExport   failureClass = "export"
+	failureOutput   failureClass = "output"
+	// failureCancelled is the export cancelled by SIGINT or SIGTERM, it is not an export error so that it is not retried.
+	failureCancelled failureClass = "cancelled"
+)
+
+// failureExitCodes are the stable exit codes of the failure classes.
+var failureExitCodes = map[failureClass]int{
+	failureInternal:  1,
+	failureInput:     2,
+	failureArchive:   3,
+	failureSigning:   4,
+	failureExport:    5,
+	failureOutput:    6,
+	failureCancelled: 7,
+}
+
+// failureSummary is the content of the failure summary file.
+type failureSummary struct {
+	Class    failureClass `json:"class"`
+	ExitCode int          `json:"exit_code"`
+	Message  string       `json:"message"`
+	// ExportFailures are the classified xcodebuild errors, if the export failed.
+	ExportFailures []exportFailure `json:"export_failures,omitempty"`
+}
+
+// failureSummaryPath is the path of the failure summary file, it is not written if empty.
+var failureSummaryPath string
+
+func fail(class failureClass, format string, v ...interface{}) {
+	failWithSummary(failureSummary{Class: class, Message: fmt.Sprintf(format, v...)})
+}
+
+// failWithSummary logs the error, writes the failure summary and exits with the exit code of the failure class.
+func failWithSummary(summary failureSummary) {
+	summary.ExitCode = failureExitCodes[summary.Class]
+
+	logError(string(summary.Class), summary.Message)
+	if failureSummaryPath != "" {
+		if err := exportFailureSummary(summary, failureSummaryPath); err != nil {
+			log.Warnf("%s", err)
+		}
+	}
+
+	finishLog("failed")
+	os.Exit(summary.ExitCode)
+}
+
+// exportFailureSummary writes the failure summary and exports its path.
+func exportFailureSummary(summary failureSummary, pth string) error {
+	if err := fileutil.WriteJSONToFile(pth, summary); err != nil {
+		return fmt.Errorf("failed to write failure summary: %s", err)
+	}
+	if err := utils.ExportOutputFile(pth, pth, bitriseFailureSummaryPathEnvKey); err != nil {
+		return fmt.Errorf("failed to export %s: %s", bitriseFailureSummaryPathEnvKey, err)
+	}
+	return nil
+}
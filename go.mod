@@ -5,6 +5,7 @@ go 1.20
 require (
 	github.com/bitrise-io/go-utils v1.0.9
 	github.com/bitrise-io/go-xcode v1.0.16
+	golang.org/x/sys v0.15.0
 )
 
 require (
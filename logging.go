@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// Formats of the step log, used by the log_format input.
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// Types of the structured log events.
+const (
+	eventPhaseStart    = "phase_start"
+	eventPhaseEnd      = "phase_end"
+	eventLog           = "log"
+	eventOutput        = "output"
+	eventSigning       = "signing"
+	eventExportFailure = "export_failure"
+	eventError         = "error"
+)
+
+// Levels of the structured log events, derived from the severity colors of the go-utils log.
+const (
+	levelError = "error"
+	levelWarn  = "warn"
+	levelInfo  = "info"
+	levelDone  = "done"
+	levelDebug = "debug"
+)
+
+var (
+	ansiEscapeRegexp = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	severityLevels   = map[string]string{
+		"\x1b[31;1m": levelError,
+		"\x1b[33;1m": levelWarn,
+		"\x1b[34;1m": levelInfo,
+		"\x1b[32;1m": levelDone,
+		"\x1b[35;1m": levelDebug,
+	}
+)
+
+// logEvent is a line of the structured (json) log.
+type logEvent struct {
+	Time     time.Time   `json:"time"`
+	Event    string      `json:"event"`
+	Level    string      `json:"level"`
+	Phase    string      `json:"phase,omitempty"`
+	Category string      `json:"category,omitempty"`
+	Message  string      `json:"message,omitempty"`
+	Data     interface{} `json:"data,omitempty"`
+}
+
+// phaseResult is the data of the phase_end event.
+type phaseResult struct {
+	Status          string  `json:"status"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// eventLogger writes the structured log events as JSON lines.
+// The go-utils log messages and everything else printed to the stdout are turned into events too.
+type eventLogger struct {
+	mu             sync.Mutex
+	encoder        *json.Encoder
+	phase          string
+	phaseStartedAt time.Time
+
+	stdout     *os.File
+	stdoutPipe *os.File
+	stdoutDone chan struct{}
+}
+
+// events is the structured logger, it is nil if the log format is text.
+var events *eventLogger
+
+// enableJSONLog switches the step log to JSON events: the log messages and the stdout are converted into events.
+func enableJSONLog() error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("failed to redirect stdout: %s", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+
+	logger := &eventLogger{
+		encoder:    encoder,
+		stdout:     os.Stdout,
+		stdoutPipe: w,
+		stdoutDone: make(chan struct{}),
+	}
+	go logger.readStdout(r)
+
+	os.Stdout = w
+	log.SetOutWriter(logWriter{logger: logger})
+	events = logger
+	return nil
+}
+
+// finishLog ends the current phase with the given status and restores the stdout.
+func finishLog(status string) {
+	if events == nil {
+		return
+	}
+
+	events.endPhase(status)
+
+	os.Stdout = events.stdout
+	if err := events.stdoutPipe.Close(); err == nil {
+		<-events.stdoutDone
+	}
+	log.SetOutWriter(os.Stdout)
+	events = nil
+}
+
+// startPhase ends the current phase successfully and starts the next one.
+func startPhase(name string) {
+	if events == nil {
+		return
+	}
+
+	events.endPhase("succeeded")
+
+	events.mu.Lock()
+	events.phase = name
+	events.phaseStartedAt = time.Now()
+	events.mu.Unlock()
+
+	events.emit(logEvent{Event: eventPhaseStart, Level: levelInfo})
+}
+
+// emitEvent writes an event of the current phase, it does nothing if the log format is text.
+func emitEvent(event logEvent) {
+	if events == nil {
+		return
+	}
+	events.emit(event)
+}
+
+// logError logs the error failing the step, in json format as an error event with the given category.
+func logError(category, message string) {
+	if events == nil {
+		log.Errorf("%s", message)
+		return
+	}
+
+	events.emit(logEvent{Event: eventError, Level: levelError, Category: category, Message: message})
+}
+
+func (l *eventLogger) endPhase(status string) {
+	l.mu.Lock()
+	phase, startedAt := l.phase, l.phaseStartedAt
+	l.phase = ""
+	l.mu.Unlock()
+
+	if phase == "" {
+		return
+	}
+
+	level := levelInfo
+	if status != "succeeded" {
+		level = levelError
+	}
+	l.emit(logEvent{
+		Event: eventPhaseEnd,
+		Level: level,
+		Phase: phase,
+		Data:  phaseResult{Status: status, DurationSeconds: time.Since(startedAt).Seconds()},
+	})
+}
+
+func (l *eventLogger) emit(event logEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if event.Phase == "" {
+		event.Phase = l.phase
+	}
+	if err := l.encoder.Encode(event); err != nil {
+		fmt.Fprintf(l.stdout, "failed to write log event: %s\n", err)
+	}
+}
+
+// readStdout turns the non empty lines printed to the stdout into output events.
+func (l *eventLogger) readStdout(r *os.File) {
+	defer close(l.stdoutDone)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := ansiEscapeRegexp.ReplaceAllString(scanner.Text(), "")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		l.emit(logEvent{Event: eventOutput, Level: levelInfo, Message: line})
+	}
+
+	// the scanner stops at a line longer than its buffer, the rest of the output is drained so that the writers do not block
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(l.stdout, "failed to read stdout: %s\n", err)
+	}
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		fmt.Fprintf(l.stdout, "failed to drain stdout: %s\n", err)
+	}
+}
+
+// logWriter converts the messages of the go-utils log into log events.
+type logWriter struct {
+	logger *eventLogger
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	message := strings.TrimSuffix(string(p), "\n")
+
+	level := levelInfo
+	for color, severityLevel := range severityLevels {
+		if strings.HasPrefix(message, color) {
+			level = severityLevel
+			break
+		}
+	}
+
+	message = ansiEscapeRegexp.ReplaceAllString(message, "")
+	if strings.TrimSpace(message) != "" {
+		w.logger.emit(logEvent{Event: eventLog, Level: level, Message: message})
+	}
+	return len(p), nil
+}
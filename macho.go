@@ -0,0 +1,132 @@
+package main
+
+import (
+	"debug/macho"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// loadCmdUUID is the LC_UUID load command, it is not defined by the debug/macho package.
+const loadCmdUUID macho.LoadCmd = 0x1b
+
+// cpuSubtypeArm64E is the CPU subtype of the arm64e architecture.
+const cpuSubtypeArm64E = 2
+
+var machOMagics = []uint32{macho.Magic32, macho.Magic64, macho.MagicFat}
+
+// machOSlice is a single architecture of a (possibly universal) Mach-O binary.
+type machOSlice struct {
+	Arch string `json:"arch"`
+	UUID string `json:"uuid"`
+}
+
+// isMachO checks the magic number of the file at the given path.
+func isMachO(pth string) (bool, error) {
+	f, err := os.Open(pth)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Warnf("Failed to close %s, error: %s", pth, err)
+		}
+	}()
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(f, header); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	for _, magic := range machOMagics {
+		if binary.BigEndian.Uint32(header) == magic || binary.LittleEndian.Uint32(header) == magic {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// machOSlices returns the architecture and LC_UUID of every slice of the Mach-O binary at the given path.
+func machOSlices(pth string) ([]machOSlice, error) {
+	fatFile, err := macho.OpenFat(pth)
+	if err == nil {
+		defer func() {
+			if err := fatFile.Close(); err != nil {
+				log.Warnf("Failed to close %s, error: %s", pth, err)
+			}
+		}()
+
+		var slices []machOSlice
+		for _, arch := range fatFile.Arches {
+			slices = append(slices, newMachOSlice(arch.File))
+		}
+		return slices, nil
+	} else if !errors.Is(err, macho.ErrNotFat) {
+		return nil, fmt.Errorf("failed to open universal binary (%s): %s", pth, err)
+	}
+
+	file, err := macho.Open(pth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Mach-O binary (%s): %s", pth, err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Warnf("Failed to close %s, error: %s", pth, err)
+		}
+	}()
+
+	return []machOSlice{newMachOSlice(file)}, nil
+}
+
+func newMachOSlice(file *macho.File) machOSlice {
+	return machOSlice{
+		Arch: machOArchName(file.Cpu, file.SubCpu),
+		UUID: machOUUID(file),
+	}
+}
+
+func machOArchName(cpu macho.Cpu, subCpu uint32) string {
+	switch cpu {
+	case macho.Cpu386:
+		return "i386"
+	case macho.CpuAmd64:
+		return "x86_64"
+	case macho.CpuArm:
+		return "arm"
+	case macho.CpuArm64:
+		if subCpu&0xff == cpuSubtypeArm64E {
+			return "arm64e"
+		}
+		return "arm64"
+	case macho.CpuPpc:
+		return "ppc"
+	case macho.CpuPpc64:
+		return "ppc64"
+	default:
+		return cpu.String()
+	}
+}
+
+func machOUUID(file *macho.File) string {
+	for _, load := range file.Loads {
+		raw := load.Raw()
+		if len(raw) < 24 {
+			continue
+		}
+		if macho.LoadCmd(file.ByteOrder.Uint32(raw[0:4])) != loadCmdUUID {
+			continue
+		}
+
+		uuid := raw[8:24]
+		return fmt.Sprintf("%X-%X-%X-%X-%X", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:16])
+	}
+	return ""
+}
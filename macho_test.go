@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// The testdata/macho fixtures are minimal 64-bit Mach-O headers with a single LC_UUID load command (or none),
+// the universal one contains an x86_64 and an arm64e slice.
+
+func TestIsMachO(t *testing.T) {
+	textPth := filepath.Join(t.TempDir(), "script.sh")
+	if err := os.WriteFile(textPth, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	emptyPth := filepath.Join(t.TempDir(), "empty")
+	if err := os.WriteFile(emptyPth, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for pth, want := range map[string]bool{
+		"testdata/macho/arm64":     true,
+		"testdata/macho/universal": true,
+		textPth:                    false,
+		emptyPth:                   false,
+	} {
+		got, err := isMachO(pth)
+		if err != nil {
+			t.Fatalf("%s: %s", pth, err)
+		}
+		if got != want {
+			t.Errorf("%s: isMachO() = %t, want %t", pth, got, want)
+		}
+	}
+}
+
+func TestMachOSlices(t *testing.T) {
+	tests := []struct {
+		pth  string
+		want []machOSlice
+	}{
+		{
+			pth:  "testdata/macho/arm64",
+			want: []machOSlice{{Arch: "arm64", UUID: "11111111-2222-3333-4444-555555555555"}},
+		},
+		{
+			pth:  "testdata/macho/arm64_no_uuid",
+			want: []machOSlice{{Arch: "arm64", UUID: ""}},
+		},
+		{
+			pth: "testdata/macho/universal",
+			want: []machOSlice{
+				{Arch: "x86_64", UUID: "AAAAAAAA-BBBB-CCCC-DDDD-EEEEEEEEEEEE"},
+				{Arch: "arm64e", UUID: "01234567-89AB-CDEF-0123-456789ABCDEF"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(filepath.Base(tt.pth), func(t *testing.T) {
+			got, err := machOSlices(tt.pth)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMachOSlicesOfInvalidBinary(t *testing.T) {
+	pth := filepath.Join(t.TempDir(), "truncated")
+	if err := os.WriteFile(pth, []byte{0xcf, 0xfa, 0xed, 0xfe, 0x0c}, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := machOSlices(pth); err == nil {
+		t.Errorf("expected an error for a truncated binary")
+	}
+}
@@ -1,28 +1,31 @@
 package main
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/bitrise-io/go-utils/fileutil"
 	"github.com/bitrise-io/go-utils/log"
 	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-io/go-xcode/export"
 	"github.com/bitrise-io/go-xcode/exportoptions"
+	"github.com/bitrise-io/go-xcode/plistutil"
 	"github.com/bitrise-io/go-xcode/utility"
-	"github.com/bitrise-io/go-xcode/xcarchive"
 	"github.com/bitrise-io/go-xcode/xcodebuild"
 	"github.com/bitrise-steplib/steps-export-xcarchive-mac/utils"
 )
 
 const (
 	bitriseAppPathEnvKey                = "BITRISE_APP_PATH"
+	bitriseAppPathListEnvKey            = "BITRISE_APP_PATH_LIST"
 	bitrisePKGPathEnvKey                = "BITRISE_PKG_PATH"
 	bitriseIDEDistributionLogsPthEnvKey = "BITRISE_IDEDISTRIBUTION_LOGS_PATH"
+	bitriseXcodebuildLogPathEnvKey      = "BITRISE_XCODEBUILD_LOG_PATH"
 )
 
 // ConfigsModel ...
@@ -33,12 +36,41 @@ type ConfigsModel struct {
 	UploadBitcode                   string
 	CompileBitcode                  string
 	TeamID                          string
+	OutputNameTemplate              string
+	MainAppBundleID                 string
+	ValidateArchive                 string
 	CustomExportOptionsPlistContent string
+	ExportAllDSYMs                  string
+	AppOutputFormat                 string
+	KeepExtendedAttributes          string
+	ComputeSHA512                   string
+	ExportTimeout                   string
+	ExportRetries                   string
+	CodeSignGroupFallback           string
+	ExportRetryDelay                string
+	XcodebuildOutputFormat          string
+	KeepDistributionLogs            string
+	XcodebuildUnsetEnvs             string
+	FailOnMissingDSYM               string
+
+	XcodePath       string
+	MinXcodeVersion string
+	MaxXcodeVersion string
+
+	SparkleAppcast                 string
+	SparkleAppcastPath             string
+	SparkleDownloadURLTemplate     string
+	SparkleReleaseNotesURLTemplate string
+	SparklePrivateKeyPath          string
 
 	UseLegacyExport                     string
 	LegacyExportProvisioningProfileName string
 	LegacyExportOutputFormat            string
 
+	OutputSink     string
+	OutputSinkPath string
+
+	LogFormat  string
 	VerboseLog string
 	DeployDir  string
 }
@@ -51,12 +83,41 @@ func createConfigsModelFromEnvs() ConfigsModel {
 		UploadBitcode:                   os.Getenv("upload_bitcode"),
 		CompileBitcode:                  os.Getenv("compile_bitcode"),
 		TeamID:                          os.Getenv("team_id"),
+		OutputNameTemplate:              os.Getenv("output_name_template"),
+		MainAppBundleID:                 os.Getenv("main_app_bundle_id"),
+		ValidateArchive:                 os.Getenv("validate_archive"),
 		CustomExportOptionsPlistContent: os.Getenv("custom_export_options_plist_content"),
+		ExportAllDSYMs:                  os.Getenv("export_all_dsyms"),
+		AppOutputFormat:                 os.Getenv("app_output_format"),
+		KeepExtendedAttributes:          os.Getenv("keep_extended_attributes"),
+		ComputeSHA512:                   os.Getenv("compute_sha512"),
+		ExportTimeout:                   os.Getenv("export_timeout"),
+		ExportRetries:                   os.Getenv("export_retries"),
+		CodeSignGroupFallback:           os.Getenv("code_sign_group_fallback"),
+		ExportRetryDelay:                os.Getenv("export_retry_delay"),
+		XcodebuildOutputFormat:          os.Getenv("xcodebuild_output_format"),
+		KeepDistributionLogs:            os.Getenv("keep_distribution_logs"),
+		XcodebuildUnsetEnvs:             os.Getenv("xcodebuild_unset_envs"),
+		FailOnMissingDSYM:               os.Getenv("fail_on_missing_dsym"),
+
+		XcodePath:       os.Getenv("xcode_path"),
+		MinXcodeVersion: os.Getenv("min_xcode_version"),
+		MaxXcodeVersion: os.Getenv("max_xcode_version"),
+
+		SparkleAppcast:                 os.Getenv("sparkle_appcast"),
+		SparkleAppcastPath:             os.Getenv("sparkle_appcast_path"),
+		SparkleDownloadURLTemplate:     os.Getenv("sparkle_download_url_template"),
+		SparkleReleaseNotesURLTemplate: os.Getenv("sparkle_release_notes_url_template"),
+		SparklePrivateKeyPath:          os.Getenv("sparkle_private_key_path"),
 
 		UseLegacyExport:                     os.Getenv("use_legacy_export"),
 		LegacyExportProvisioningProfileName: os.Getenv("legacy_export_provisioning_profile_name"),
 		LegacyExportOutputFormat:            os.Getenv("legacy_export_output_format"),
 
+		OutputSink:     os.Getenv("output_sink"),
+		OutputSinkPath: os.Getenv("output_sink_path"),
+
+		LogFormat:  os.Getenv("log_format"),
 		DeployDir:  os.Getenv("BITRISE_DEPLOY_DIR"),
 		VerboseLog: os.Getenv("verbose_log"),
 	}
@@ -69,15 +130,45 @@ func (configs ConfigsModel) print() {
 	log.Printf("- UploadBitcode: %s", configs.UploadBitcode)
 	log.Printf("- CompileBitcode: %s", configs.CompileBitcode)
 	log.Printf("- TeamID: %s", configs.TeamID)
+	log.Printf("- OutputNameTemplate: %s", configs.OutputNameTemplate)
+	log.Printf("- MainAppBundleID: %s", configs.MainAppBundleID)
+	log.Printf("- ValidateArchive: %s", configs.ValidateArchive)
+	log.Printf("- ExportAllDSYMs: %s", configs.ExportAllDSYMs)
+	log.Printf("- FailOnMissingDSYM: %s", configs.FailOnMissingDSYM)
+	log.Printf("- AppOutputFormat: %s", configs.AppOutputFormat)
+	log.Printf("- KeepExtendedAttributes: %s", configs.KeepExtendedAttributes)
+	log.Printf("- ComputeSHA512: %s", configs.ComputeSHA512)
+	log.Printf("- ExportTimeout: %s", configs.ExportTimeout)
+	log.Printf("- ExportRetries: %s", configs.ExportRetries)
+	log.Printf("- ExportRetryDelay: %s", configs.ExportRetryDelay)
+	log.Printf("- CodeSignGroupFallback: %s", configs.CodeSignGroupFallback)
+	log.Printf("- XcodebuildOutputFormat: %s", configs.XcodebuildOutputFormat)
+	log.Printf("- KeepDistributionLogs: %s", configs.KeepDistributionLogs)
+	log.Printf("- XcodebuildUnsetEnvs: %s", strings.Join(strings.Fields(configs.XcodebuildUnsetEnvs), ", "))
+	log.Printf("- OutputSink: %s", configs.OutputSink)
+	log.Printf("- OutputSinkPath: %s", configs.OutputSinkPath)
+	log.Printf("- LogFormat: %s", configs.LogFormat)
 	log.Printf("- VerboseLog: %s", configs.VerboseLog)
 
+	log.Infof("Xcode Configs:")
+	log.Printf("- XcodePath: %s", configs.XcodePath)
+	log.Printf("- MinXcodeVersion: %s", configs.MinXcodeVersion)
+	log.Printf("- MaxXcodeVersion: %s", configs.MaxXcodeVersion)
+
+	log.Infof("Sparkle Configs:")
+	log.Printf("- SparkleAppcast: %s", configs.SparkleAppcast)
+	log.Printf("- SparkleAppcastPath: %s", configs.SparkleAppcastPath)
+	log.Printf("- SparkleDownloadURLTemplate: %s", configs.SparkleDownloadURLTemplate)
+	log.Printf("- SparkleReleaseNotesURLTemplate: %s", configs.SparkleReleaseNotesURLTemplate)
+	log.Printf("- SparklePrivateKeyPath: %s", configs.SparklePrivateKeyPath)
+
 	log.Infof("Experimental Configs:")
 	log.Printf("- UseLegacyExport: %s", configs.UseLegacyExport)
 	log.Printf("- LegacyExportProvisioningProfileName: %s", configs.LegacyExportProvisioningProfileName)
 	log.Printf("- LegacyExportOutputFormat: %s", configs.LegacyExportOutputFormat)
 	log.Printf("- CustomExportOptionsPlistContent:")
 	if configs.CustomExportOptionsPlistContent != "" {
-		fmt.Println(configs.CustomExportOptionsPlistContent)
+		log.Printf("%s", configs.CustomExportOptionsPlistContent)
 	}
 
 	log.Infof("Other Configs:")
@@ -98,12 +189,97 @@ func (configs ConfigsModel) validate() error {
 	if configs.ExportMethod == "" {
 		return errors.New("no ExportMethod specified")
 	}
+	if err := validateOutputNameTemplate(configs.OutputNameTemplate); err != nil {
+		return fmt.Errorf("invalid OutputNameTemplate: %s", err)
+	}
 	if configs.UploadBitcode == "" {
 		return errors.New("no UploadBitcode specified")
 	}
 	if configs.CompileBitcode == "" {
 		return errors.New("no CompileBitcode specified")
 	}
+	if configs.ValidateArchive == "" {
+		return errors.New("no ValidateArchive specified")
+	}
+	if configs.ExportAllDSYMs == "" {
+		return errors.New("no ExportAllDSYMs specified")
+	}
+	if configs.FailOnMissingDSYM == "" {
+		return errors.New("no FailOnMissingDSYM specified")
+	}
+	if _, err := parseAppOutputFormat(configs.AppOutputFormat); err != nil {
+		return err
+	}
+	if configs.KeepExtendedAttributes == "" {
+		return errors.New("no KeepExtendedAttributes specified")
+	}
+	if configs.ComputeSHA512 == "" {
+		return errors.New("no ComputeSHA512 specified")
+	}
+	if timeout, err := strconv.Atoi(configs.ExportTimeout); err != nil || timeout < 0 {
+		return fmt.Errorf("invalid ExportTimeout: %s, it has to be a non negative number of seconds", configs.ExportTimeout)
+	}
+	if retries, err := strconv.Atoi(configs.ExportRetries); err != nil || retries < 0 || retries > maxExportRetries {
+		return fmt.Errorf("invalid ExportRetries: %s, it has to be a number between 0 and %d", configs.ExportRetries, maxExportRetries)
+	}
+	if delay, err := strconv.Atoi(configs.ExportRetryDelay); err != nil || delay < 0 || delay > int(maxExportRetryDelay/time.Second) {
+		return fmt.Errorf("invalid ExportRetryDelay: %s, it has to be a number of seconds between 0 and %d", configs.ExportRetryDelay, int(maxExportRetryDelay/time.Second))
+	}
+	if configs.CodeSignGroupFallback == "" {
+		return errors.New("no CodeSignGroupFallback specified")
+	}
+	if configs.XcodebuildOutputFormat != xcodebuildOutputFormatRaw && configs.XcodebuildOutputFormat != xcodebuildOutputFormatPretty {
+		return fmt.Errorf("invalid XcodebuildOutputFormat: %s, available formats: %s, %s", configs.XcodebuildOutputFormat, xcodebuildOutputFormatRaw, xcodebuildOutputFormatPretty)
+	}
+	if configs.KeepDistributionLogs == "" {
+		return errors.New("no KeepDistributionLogs specified")
+	}
+	if _, err := parseEnvSanitizer(configs.XcodebuildUnsetEnvs); err != nil {
+		return fmt.Errorf("invalid XcodebuildUnsetEnvs: %s", err)
+	}
+
+	if configs.XcodePath != "" {
+		if _, err := resolveDeveloperDir(configs.XcodePath); err != nil {
+			return fmt.Errorf("invalid XcodePath: %s", err)
+		}
+	}
+	if configs.MinXcodeVersion != "" {
+		if _, err := parseXcodeVersion(configs.MinXcodeVersion); err != nil {
+			return fmt.Errorf("invalid MinXcodeVersion: %s", err)
+		}
+	}
+	if configs.MaxXcodeVersion != "" {
+		if _, err := parseXcodeVersion(configs.MaxXcodeVersion); err != nil {
+			return fmt.Errorf("invalid MaxXcodeVersion: %s", err)
+		}
+	}
+
+	if configs.OutputSink == "" {
+		return errors.New("no OutputSink specified")
+	}
+	if configs.LogFormat != logFormatText && configs.LogFormat != logFormatJSON {
+		return fmt.Errorf("invalid LogFormat: %s, available formats: %s, %s", configs.LogFormat, logFormatText, logFormatJSON)
+	}
+
+	if configs.SparkleAppcast == "" {
+		return errors.New("no SparkleAppcast specified")
+	}
+	if configs.SparkleAppcast == "yes" {
+		if configs.SparkleDownloadURLTemplate == "" {
+			return errors.New("no SparkleDownloadURLTemplate specified")
+		}
+		if configs.AppOutputFormat == string(appOutputFormatDirectory) {
+			return errors.New("Sparkle appcast requires the app to be zipped, set AppOutputFormat to zip or both")
+		}
+		if configs.SparklePrivateKeyPath == "" {
+			return errors.New("no SparklePrivateKeyPath specified")
+		}
+		if exist, err := pathutil.IsPathExists(configs.SparklePrivateKeyPath); err != nil {
+			return fmt.Errorf("failed to check if SparklePrivateKeyPath exist at: %s, error: %s", configs.SparklePrivateKeyPath, err)
+		} else if !exist {
+			return fmt.Errorf("SparklePrivateKeyPath not exist at: %s", configs.SparklePrivateKeyPath)
+		}
+	}
 
 	if configs.UseLegacyExport == "" {
 		return errors.New("no UseLegacyExport specified")
@@ -115,55 +291,101 @@ func (configs ConfigsModel) validate() error {
 	return nil
 }
 
-func fail(format string, v ...interface{}) {
-	log.Errorf(format, v...)
-	os.Exit(1)
-}
+func main() {
+	configs := createConfigsModelFromEnvs()
 
-func findIDEDistrubutionLogsPath(output string) (string, error) {
-	pattern := `IDEDistribution: -\[IDEDistributionLogging _createLoggingBundleAtPath:\]: Created bundle at path "(?P<log_path>.*)"`
-	re := regexp.MustCompile(pattern)
+	// the output name is rendered from the archive, the failures before it use the default name
+	if configs.DeployDir != "" {
+		failureSummaryPath = artifactNamer{deployDir: configs.DeployDir}.auxiliaryPath(failureSummaryFileName)
+	}
 
-	scanner := bufio.NewScanner(strings.NewReader(output))
-	for scanner.Scan() {
-		line := scanner.Text()
-		if match := re.FindStringSubmatch(line); len(match) == 2 {
-			return match[1], nil
+	if configs.LogFormat == logFormatJSON {
+		if err := enableJSONLog(); err != nil {
+			fail(failureInternal, "Failed to enable json log format, error: %s", err)
 		}
+		defer finishLog("succeeded")
 	}
-	if err := scanner.Err(); err != nil {
-		return "", err
-	}
-
-	return "", nil
-}
-
-func main() {
-	configs := createConfigsModelFromEnvs()
+	startPhase("configs")
 
 	fmt.Println()
 	configs.print()
 
 	if err := configs.validate(); err != nil {
-		fail("Issue with input: %s", err)
+		fail(failureInput, "Issue with input: %s", err)
 	}
 
 	log.SetEnableDebugLog(configs.VerboseLog == "yes")
+	utils.SetComputeSHA512(configs.ComputeSHA512 == "yes")
+
+	outputSink, err := utils.NewOutputSink(configs.OutputSink, configs.OutputSinkPath, configs.DeployDir)
+	if err != nil {
+		fail(failureInput, "Issue with input: %s", err)
+	}
+	utils.SetOutputSink(outputSink)
+	log.Printf("- outputSink: %s", outputSink.Name())
 
 	archiveExt := filepath.Ext(configs.ArchivePath)
 	archiveName := filepath.Base(configs.ArchivePath)
 	archiveName = strings.TrimSuffix(archiveName, archiveExt)
-	appPath := filepath.Join(configs.DeployDir, archiveName+".app")
-	pkgPath := filepath.Join(configs.DeployDir, archiveName+".pkg")
-	exportOptionsPath := filepath.Join(configs.DeployDir, "export_options.plist")
-	ideDistributionLogsZipPath := filepath.Join(configs.DeployDir, "xcodebuild.xcdistributionlogs.zip")
+
+	exportTimeoutSeconds, err := strconv.Atoi(configs.ExportTimeout)
+	if err != nil {
+		fail(failureInput, "Issue with input: %s", err)
+	}
+	exportTimeout := time.Duration(exportTimeoutSeconds) * time.Second
+
+	exportRetries, err := strconv.Atoi(configs.ExportRetries)
+	if err != nil {
+		fail(failureInput, "Issue with input: %s", err)
+	}
+	exportRetryDelaySeconds, err := strconv.Atoi(configs.ExportRetryDelay)
+	if err != nil {
+		fail(failureInput, "Issue with input: %s", err)
+	}
+	exportRetryBaseDelay := time.Duration(exportRetryDelaySeconds) * time.Second
+
+	appFormat, err := parseAppOutputFormat(configs.AppOutputFormat)
+	if err != nil {
+		fail(failureInput, "Issue with input: %s", err)
+	}
+
+	var zipOptions []utils.ZipOption
+	if configs.KeepExtendedAttributes == "yes" {
+		zipOptions = append(zipOptions, utils.WithAppleDouble())
+	}
+
+	startPhase("xcode")
+
+	// every xcodebuild and codesign invocation of the step uses the selected Xcode
+	if configs.XcodePath != "" {
+		developerDir, err := resolveDeveloperDir(configs.XcodePath)
+		if err != nil {
+			fail(failureInput, "Issue with input: %s", err)
+		}
+		if err := os.Setenv(developerDirEnvKey, developerDir); err != nil {
+			fail(failureInternal, "Failed to set %s, error: %s", developerDirEnvKey, err)
+		}
+	}
+
+	developerDir, err := selectedDeveloperDir()
+	if err != nil {
+		fail(failureInternal, "Failed to determine the Xcode Developer dir, error: %s", err)
+	}
+	log.Printf("- developerDir: %s", developerDir)
 
 	xcodebuildVersion, err := utility.GetXcodeVersion()
 	if err != nil {
-		fail("Failed to determine xcode version, error: %s", err)
+		fail(failureInternal, "Failed to determine xcode version, error: %s", err)
 	}
 	log.Printf("- xcodebuildVersion: %s (%s)", xcodebuildVersion.Version, xcodebuildVersion.BuildVersion)
 
+	if err := checkXcodeVersion(xcodeVersionNumber(xcodebuildVersion), configs.MinXcodeVersion, configs.MaxXcodeVersion); err != nil {
+		fail(failureInput, "Unsupported Xcode: %s", err)
+	}
+	if err := exportXcodeSelection(xcodebuildVersion, developerDir); err != nil {
+		fail(failureOutput, "Failed to export the Xcode version, error: %s", err)
+	}
+
 	customExportOptionsPlistContent := strings.TrimSpace(configs.CustomExportOptionsPlistContent)
 	if customExportOptionsPlistContent != configs.CustomExportOptionsPlistContent {
 		fmt.Println()
@@ -171,16 +393,146 @@ func main() {
 		log.Printf(customExportOptionsPlistContent)
 	}
 
-	envsToUnset := []string{"GEM_HOME", "GEM_PATH", "RUBYLIB", "RUBYOPT", "BUNDLE_BIN_PATH", "_ORIGINAL_GEM_PATH", "BUNDLE_GEMFILE"}
-	for _, key := range envsToUnset {
-		if err := os.Unsetenv(key); err != nil {
-			fail("Failed to unset (%s), error: %s", key, err)
+	envSanitizer, err := parseEnvSanitizer(configs.XcodebuildUnsetEnvs)
+	if err != nil {
+		fail(failureInput, "Issue with input: %s", err)
+	}
+	xcodebuildEnv, removedEnvs := envSanitizer.environ(os.Environ())
+	log.Debugf("Environment variables removed for xcodebuild: %s", strings.Join(removedEnvs, ", "))
+
+	startPhase("archive")
+
+	kind, productPths, err := detectProducts(configs.ArchivePath)
+	if err != nil {
+		fail(failureArchive, "Failed to find products in the archive, error: %s", err)
+	}
+	log.Printf("- productKind: %s", kind)
+
+	archiveInfoPlist, err := plistutil.NewPlistDataFromFile(filepath.Join(configs.ArchivePath, "Info.plist"))
+	if err != nil {
+		fail(failureArchive, "Failed to parse archive Info.plist, error: %s", err)
+	}
+
+	var archive macosArchive
+	if kind == productKindApp {
+		fmt.Println()
+
+		archive, err = newMacosArchive(configs.ArchivePath, productPths, configs.MainAppBundleID)
+		if err != nil {
+			fail(failureArchive, "Failed to parse archive, error: %s", err)
+		}
+
+		log.Printf("Applications:")
+		for _, app := range archive.Applications {
+			log.Printf("- %s (%s)", filepath.Base(app.Path), app.BundleIdentifier())
 		}
+		log.Printf("Main application: %s", filepath.Base(archive.Application.Path))
+		fmt.Println()
+
+		if configs.ValidateArchive == "yes" {
+			log.Infof("Validating archive...")
+
+			if err := archive.validate(); err != nil {
+				fail(failureArchive, "%s", err)
+			}
+
+			log.Donef("Archive is valid")
+			fmt.Println()
+		}
+	}
+
+	namer := artifactNamer{deployDir: configs.DeployDir, baseName: archiveName}
+	if configs.OutputNameTemplate != "" {
+		values := newOutputNameValues(archiveName, archiveInfoPlist, archive.Application.InfoPlist, configs.ExportMethod, configs.TeamID, time.Now())
+		outputName, err := renderOutputName(configs.OutputNameTemplate, values)
+		if err != nil {
+			fail(failureInput, "Failed to render OutputNameTemplate, error: %s", err)
+		}
+
+		log.Printf("- outputName: %s", outputName)
+		namer = artifactNamer{deployDir: configs.DeployDir, baseName: outputName, prefixAuxiliary: true}
+	}
+	if configs.DeployDir != "" {
+		failureSummaryPath = namer.auxiliaryPath(failureSummaryFileName)
 	}
 
-	archive, err := xcarchive.NewMacosArchive(configs.ArchivePath)
+	pkgPath := namer.artifactPath(".pkg")
+	exportOptionsPath := namer.auxiliaryPath("export_options.plist")
+	ideDistributionLogsZipPath := namer.auxiliaryPath("xcodebuild.xcdistributionlogs.zip")
+	exportReportPath := namer.auxiliaryPath("export_report.json")
+	dsymUUIDsPath := namer.auxiliaryPath("dsym_uuids.json")
+	appcastPath := namer.auxiliaryPath("appcast.xml")
+	sha256SumsPath := namer.auxiliaryPath("SHA256SUMS")
+	artifactManifestPath := namer.auxiliaryPath("artifacts.json")
+	xcodebuildLogPath := namer.auxiliaryPath("xcodebuild_export.log")
+
+	// checksums are exported after every successful export, fail() exits without running the deferred calls
+	defer func() {
+		startPhase("checksums")
+
+		fmt.Println()
+		log.Infof("Exporting checksums...")
+
+		if err := exportChecksums(sha256SumsPath, artifactManifestPath); err != nil {
+			fail(failureOutput, "Failed to export checksums, error: %s", err)
+		}
+	}()
+
+	startPhase("dsyms")
+
+	fmt.Println()
+	log.Infof("Exporting dSYMs...")
+
+	if err := exportDSYMs(configs.ArchivePath, productPths, namer, configs.ExportAllDSYMs == "yes"); err != nil {
+		fail(failureOutput, "Failed to export dSYMs, error: %s", err)
+	}
+
+	fmt.Println()
+	log.Infof("Matching executable and dSYM UUIDs...")
+
+	// the embedded frameworks are matched with their dSYMs too, even if only the product dSYMs are exported
+	archiveDSYMs, err := findDSYMs(configs.ArchivePath)
+	if err != nil {
+		fail(failureArchive, "Failed to match executable and dSYM UUIDs, error: %s", err)
+	}
+	unmatchedBinaries, err := checkDSYMUUIDs(productPths, archiveDSYMs, dsymUUIDsPath)
 	if err != nil {
-		fail("Failed to parse archive, error: %s", err)
+		fail(failureArchive, "Failed to match executable and dSYM UUIDs, error: %s", err)
+	}
+	if len(unmatchedBinaries) > 0 {
+		if configs.FailOnMissingDSYM == "yes" {
+			fail(failureArchive, "No matching dSYM found for the binaries:\n%s", unmatchedBinariesDescription(unmatchedBinaries))
+		}
+		log.Warnf("No matching dSYM found for the binaries:\n%s", unmatchedBinariesDescription(unmatchedBinaries))
+	}
+	fmt.Println()
+
+	startPhase("export")
+
+	// command-line tools and frameworks are exported as they were signed when archiving
+	{
+		if kind != productKindApp {
+			if configs.ExportMethod != "none" {
+				log.Warnf("The archive contains %s products, which can not be exported by xcodebuild, exporting them without re-sign...", kind)
+			}
+			log.Infof("Exporting %s products...", kind)
+
+			if err := exportProducts(kind, productPths, namer, zipOptions...); err != nil {
+				fail(failureOutput, "Failed to export products, error: %s", err)
+			}
+
+			fmt.Println()
+			log.Infof("Writing artifact metadata...")
+
+			metadataPth, err := writeProductsMetadata(kind, productPths, namer, configs.TeamID)
+			if err != nil {
+				fail(failureOutput, "Failed to write artifact metadata, error: %s", err)
+			}
+			if err := exportArtifactMetadata(metadataPth); err != nil {
+				fail(failureOutput, "Failed to export artifact metadata, error: %s", err)
+			}
+			return
+		}
 	}
 
 	// do a simple export if method set to none
@@ -188,18 +540,27 @@ func main() {
 		if configs.ExportMethod == "none" {
 			log.Infof("Exporting app without re-sign...")
 
-			if err := utils.ExportAppFromArchive(configs.ArchivePath, configs.DeployDir, bitriseAppPathEnvKey); err != nil {
-				fail("Failed to archive app, error: %s", err)
+			if err := exportApps(archive.AppPaths(), namer, appFormat, zipOptions...); err != nil {
+				fail(failureOutput, "Failed to export apps, error: %s", err)
 			}
 
-			log.Donef("The app path is now available in the Environment Variable: %s", bitriseAppPathEnvKey)
+			fmt.Println()
+			log.Infof("Writing artifact metadata...")
+
+			metadataPth, err := writeAppsMetadata(archive.AppPaths(), namer, appFormat, configs.ExportMethod, configs.TeamID)
+			if err != nil {
+				fail(failureOutput, "Failed to write artifact metadata, error: %s", err)
+			}
+			if err := exportArtifactMetadata(metadataPth); err != nil {
+				fail(failureOutput, "Failed to export artifact metadata, error: %s", err)
+			}
 			return
 		}
 	}
 
 	exportMethod, err := exportoptions.ParseMethod(configs.ExportMethod)
 	if err != nil {
-		fail("Failed to parse export options, error: %s", err)
+		fail(failureInput, "Failed to parse export options, error: %s", err)
 	}
 
 	// legacy export
@@ -208,7 +569,7 @@ func main() {
 			log.Infof("Using legacy export method...")
 
 			if xcodebuildVersion.MajorVersion >= 9 {
-				fail("Legacy export method (using '-exportFormat ipa' flag) is not supported from Xcode version 9")
+				fail(failureInput, "Legacy export method (using '-exportFormat ipa' flag) is not supported from Xcode version 9")
 			}
 
 			provisioningProfileName := ""
@@ -220,7 +581,7 @@ func main() {
 				log.Printf("Using embedded provisioning profile")
 
 				if archive.Application.ProvisioningProfile == nil {
-					fail("No embedded.provisionprofile found nor Provisioning Profile name to use by export specified")
+					fail(failureSigning, "No embedded.provisionprofile found nor Provisioning Profile name to use by export specified")
 				}
 
 				provisioningProfileName = archive.Application.ProvisioningProfile.Name
@@ -235,8 +596,15 @@ func main() {
 			legacyExportCmd := xcodebuild.NewLegacyExportCommand()
 			legacyExportCmd.SetExportFormat(configs.LegacyExportOutputFormat)
 
+			// the app is exported into a temp dir, and placed into the deploy dir according to the app output format
+			legacyAppPath := ""
 			if exportingApp {
-				legacyExportCmd.SetExportPath(appPath)
+				tmpDir, err := pathutil.NormalizedOSTempDirPath("__legacy_export__")
+				if err != nil {
+					fail(failureInternal, "Failed to create tmp dir, error: %s", err)
+				}
+				legacyAppPath = filepath.Join(tmpDir, filepath.Base(archive.Application.Path))
+				legacyExportCmd.SetExportPath(legacyAppPath)
 			} else {
 				legacyExportCmd.SetExportPath(pkgPath)
 			}
@@ -247,22 +615,25 @@ func main() {
 			log.Donef("$ %s", legacyExportCmd.PrintableCmd())
 			fmt.Println()
 
-			if err := legacyExportCmd.Run(); err != nil {
-				fail("Export failed, error: %s", err)
+			cmd := legacyExportCmd.Command()
+			cmd.SetStdout(os.Stdout)
+			cmd.SetStderr(os.Stderr)
+			cmd.SetEnvs(xcodebuildEnv...)
+
+			if err := cmd.Run(); err != nil {
+				fail(failureExport, "Export failed, error: %s", err)
 			}
 
 			if exportingApp {
-				if err := utils.ExportOutputFile(appPath, appPath, bitriseAppPathEnvKey); err != nil {
-					fail("Failed to export %s, error: %s", bitriseAppPathEnvKey, err)
+				if err := exportApps([]string{legacyAppPath}, namer, appFormat, zipOptions...); err != nil {
+					fail(failureOutput, "Failed to export apps, error: %s", err)
 				}
-
-				log.Donef("The app path is now available in the Environment Variable: %s (value: %s)", bitriseAppPathEnvKey, appPath)
 			} else {
 				if err := utils.ExportOutputFile(pkgPath, pkgPath, bitrisePKGPathEnvKey); err != nil {
-					fail("Failed to export %s, error: %s", bitrisePKGPathEnvKey, err)
+					fail(failureOutput, "Failed to export %s, error: %s", bitrisePKGPathEnvKey, err)
 				}
 
-				log.Donef("The pkg path is now available in the Environment Variable: %s (value: %s)", bitrisePKGPathEnvKey, appPath)
+				log.Donef("The pkg path is now available in the Environment Variable: %s (value: %s)", bitrisePKGPathEnvKey, pkgPath)
 			}
 
 			return
@@ -275,95 +646,288 @@ func main() {
 
 	if customExportOptionsPlistContent != "" {
 		log.Printf("Custom export options content provided:")
-		fmt.Println(customExportOptionsPlistContent)
+		log.Printf("%s", customExportOptionsPlistContent)
 
 		exportOptionsPlistContent = customExportOptionsPlistContent
+		emitEvent(logEvent{Event: eventSigning, Level: levelInfo, Message: "Using custom export options"})
 	}
 
+	var codeSignGroups []export.MacCodeSignGroup
 	if exportOptionsPlistContent == "" {
 		log.Printf("Generating export options")
 
 		if xcodebuildVersion.MajorVersion >= 9 {
 			log.Printf("xcode major version > 9, generating provisioningProfiles node")
 
-			exportOpts, err := generateMacExportOptionsPlist(archive, exportMethod, configs.TeamID)
+			codeSignGroups, err = resolveMacCodeSignGroups(archive, exportMethod, configs.TeamID)
 			if err != nil {
-				fail("Export options could not be generated: %v", err)
+				fail(failureSigning, "Export options could not be generated: %v", err)
 			}
 
-			log.Printf("generated export options content:")
-			fmt.Println()
-			fmt.Println(exportOpts.String())
-
-			exportOptionsPlistContent, err = exportOpts.String()
+			exportOptionsPlistContent, err = macExportOptionsContent(codeSignGroups, 0, exportMethod)
 			if err != nil {
-				fail("Failed to get exportOptions, error: %s", err)
+				fail(failureSigning, "Failed to get exportOptions, error: %s", err)
 			}
 		}
 	}
 
-	if err := fileutil.WriteStringToFile(exportOptionsPath, exportOptionsPlistContent); err != nil {
-		fail("Failed to write export options to file, error: %s", err)
+	// the custom export options might use a different export method
+	exportedMethod := exportMethod
+	if exportOptions, err := plistutil.NewPlistDataFromContent(exportOptionsPlistContent); err != nil {
+		log.Warnf("Failed to parse export options, error: %s", err)
+	} else if method, found := exportOptions.GetString(exportoptions.MethodKey); found {
+		exportedMethod = exportoptions.Method(method)
 	}
 
-	fmt.Println()
+	// the raw xcodebuild output goes to the log file only, the errors and warnings are logged as events in json format
+	outputFormat := configs.XcodebuildOutputFormat
+	if configs.LogFormat == logFormatJSON {
+		outputFormat = xcodebuildOutputFormatPretty
+	}
 
-	tmpDir, err := pathutil.NormalizedOSTempDirPath("__export__")
+	xcodebuildLog, err := os.Create(xcodebuildLogPath)
 	if err != nil {
-		fail("Failed to create tmp dir, error: %s", err)
+		fail(failureOutput, "Failed to create xcodebuild log, error: %s", err)
 	}
 
-	exportCmd := xcodebuild.NewExportCommand()
-	exportCmd.SetArchivePath(configs.ArchivePath)
-	exportCmd.SetExportDir(tmpDir)
-	exportCmd.SetExportOptionsPlist(exportOptionsPath)
+	attemptConfig := exportAttemptConfig{
+		ArchivePath:       configs.ArchivePath,
+		ExportOptionsPath: exportOptionsPath,
+		OutputFormat:      outputFormat,
+		RawLog:            xcodebuildLog,
+		Env:               xcodebuildEnv,
+		Timeout:           exportTimeout,
+		Retries:           exportRetries,
+		RetryBaseDelay:    exportRetryBaseDelay,
+	}
 
-	log.Donef("$ %s", exportCmd.PrintableCmd())
-	fmt.Println()
+	var attempts []exportAttempt
+	var codeSignGroup *codeSignGroupReport
+	for groupIdx := 0; ; groupIdx++ {
+		if groupIdx > 0 {
+			fmt.Println()
+			log.Warnf("Export failed with code sign group %d/%d, falling back to the next group...", groupIdx, len(codeSignGroups))
+
+			exportOptionsPlistContent, err = macExportOptionsContent(codeSignGroups, groupIdx, exportMethod)
+			if err != nil {
+				fail(failureSigning, "Failed to get exportOptions, error: %s", err)
+			}
+		}
+
+		if err := fileutil.WriteStringToFile(exportOptionsPath, exportOptionsPlistContent); err != nil {
+			fail(failureOutput, "Failed to write export options to file, error: %s", err)
+		}
+
+		if groupIdx < len(codeSignGroups) {
+			report := newCodeSignGroupReport(groupIdx, codeSignGroups[groupIdx])
+			codeSignGroup = &report
+			log.Printf("Using code sign group %d/%d, certificate: %s", report.Index, len(codeSignGroups), report.Certificate)
+			emitEvent(logEvent{Event: eventSigning, Level: levelInfo, Message: fmt.Sprintf("Using code sign group %d/%d", report.Index, len(codeSignGroups)), Data: report})
+		}
+		fmt.Println()
+
+		groupAttempts, err := runExportAttempts(len(attempts)+1, attemptConfig)
+		if err != nil {
+			// the attempts fail by themselves, the error is about the temp dir or the xcodebuild log
+			fail(failureInternal, "Failed to run the export, error: %s", err)
+		}
+		for i := range groupAttempts {
+			if codeSignGroup != nil {
+				groupAttempts[i].CodeSignGroup = codeSignGroup.Index
+			}
+		}
+		attempts = append(attempts, groupAttempts...)
+
+		lastAttempt := attempts[len(attempts)-1]
+		if !lastAttempt.fallsBackToNextGroup(configs.CodeSignGroupFallback == "yes", groupIdx, len(codeSignGroups)) {
+			break
+		}
+
+		if err := os.RemoveAll(lastAttempt.exportDir); err != nil {
+			log.Warnf("Failed to remove the export dir, error: %s", err)
+		}
+	}
+
+	if err := xcodebuildLog.Close(); err != nil {
+		log.Warnf("Failed to close xcodebuild log, error: %s", err)
+	}
+	if err := utils.ExportOutputFile(xcodebuildLogPath, xcodebuildLogPath, bitriseXcodebuildLogPathEnvKey); err != nil {
+		log.Warnf("Failed to export %s, error: %s", bitriseXcodebuildLogPathEnvKey, err)
+	} else {
+		log.Donef("The xcodebuild log path is now available in the Environment Variable: %s (value: %s)", bitriseXcodebuildLogPathEnvKey, xcodebuildLogPath)
+	}
+
+	lastAttempt := attempts[len(attempts)-1]
+	tmpDir := lastAttempt.exportDir
+	xcodebuildOut := lastAttempt.output
+
+	if lastAttempt.err != nil {
+		report := exportReport{Status: "failed", ExportMethod: string(exportedMethod), Failures: lastAttempt.Failures, Attempts: attempts, CodeSignGroup: codeSignGroup}
 
-	if xcodebuildOut, err := exportCmd.RunAndReturnOutput(); err != nil {
 		// xcdistributionlogs
-		if logsDirPth, err := findIDEDistrubutionLogsPath(xcodebuildOut); err != nil {
-			log.Warnf("Failed to find xcdistributionlogs, error: %s", err)
-		} else if err := utils.ExportOutputDirAsZip(logsDirPth, ideDistributionLogsZipPath, bitriseIDEDistributionLogsPthEnvKey); err != nil {
-			log.Warnf("Failed to export %s, error: %s", bitriseIDEDistributionLogsPthEnvKey, err)
+		logsDirPth := xcodebuildOut.IDEDistributionLogsPath
+		if logsDirPth == "" && lastAttempt.interrupted {
+			logsDirPth = findRecentDistributionLogs(lastAttempt.StartedAt)
+		}
+		if logsDirPth == "" {
+			log.Warnf("No xcdistributionlogs found in the xcodebuild output")
 		} else {
-			log.Warnf(`If you can't find the reason of the error in the log, please check the xcdistributionlogs
+			fmt.Println()
+			log.Infof("xcdistributionlogs summary:")
+
+			if summary, err := readDistributionLogs(logsDirPth); err != nil {
+				log.Warnf("Failed to read xcdistributionlogs, error: %s", err)
+			} else {
+				printDistributionLogSummary(summary)
+				report.DistributionLogs = &summary
+			}
+			fmt.Println()
+
+			if err := utils.ExportOutputDirAsZip(logsDirPth, ideDistributionLogsZipPath, bitriseIDEDistributionLogsPthEnvKey); err != nil {
+				log.Warnf("Failed to export %s, error: %s", bitriseIDEDistributionLogsPthEnvKey, err)
+			} else {
+				log.Warnf(`If you can't find the reason of the error in the log, please check the xcdistributionlogs
 The logs directory is stored in $BITRISE_DEPLOY_DIR, and its full path
 is available in the $BITRISE_IDEDISTRIBUTION_LOGS_PATH environment variable`)
+			}
+		}
+
+		if lastAttempt.interrupted {
+			if err := os.RemoveAll(tmpDir); err != nil {
+				log.Warnf("Failed to remove the export dir, error: %s", err)
+			}
+		}
+		printExportFailures(report.Failures)
+
+		if err := exportExportReport(report, exportReportPath); err != nil {
+			log.Warnf("Failed to export the export report, error: %s", err)
 		}
 
-		fail("Export failed, error: %s", err)
+		class := failureExport
+		var cancelled exportCancelledError
+		if errors.As(lastAttempt.err, &cancelled) {
+			class = failureCancelled
+		}
+		failWithSummary(failureSummary{Class: class, Message: fmt.Sprintf("Export failed, error: %s", lastAttempt.err), ExportFailures: report.Failures})
 	}
 
-	pattern := filepath.Join(tmpDir, "*.app")
-	apps, err := filepath.Glob(pattern)
-	if err != nil {
-		fail("Failed to find app, with pattern: %s, error: %s", pattern, err)
+	if err := exportExportReport(exportReport{Status: "succeeded", ExportMethod: string(exportedMethod), Attempts: attempts, CodeSignGroup: codeSignGroup}, exportReportPath); err != nil {
+		fail(failureOutput, "Failed to export the export report, error: %s", err)
 	}
 
-	if len(apps) > 0 {
-		if err := utils.ExportOutputDirAsZip(apps[0], appPath, bitriseAppPathEnvKey); err != nil {
-			fail("Failed to export %s, error: %s", bitriseAppPathEnvKey, err)
+	if configs.KeepDistributionLogs == "yes" {
+		if logsDirPth := xcodebuildOut.IDEDistributionLogsPath; logsDirPth == "" {
+			log.Warnf("No xcdistributionlogs found in the xcodebuild output")
+		} else if err := utils.ExportOutputDirAsZip(logsDirPth, ideDistributionLogsZipPath, bitriseIDEDistributionLogsPthEnvKey); err != nil {
+			fail(failureOutput, "Failed to export %s, error: %s", bitriseIDEDistributionLogsPthEnvKey, err)
+		} else {
+			log.Donef("The xcdistributionlogs path is now available in the Environment Variable: %s (value: %s)", bitriseIDEDistributionLogsPthEnvKey, ideDistributionLogsZipPath)
+		}
+	}
+
+	if err := exportXcodebuildExportFiles(tmpDir, namer); err != nil {
+		fail(failureOutput, "Failed to export xcodebuild export files, error: %s", err)
+	}
+
+	distributionSummaryPth := filepath.Join(tmpDir, distributionSummaryPlistName)
+	if exist, err := pathutil.IsPathExists(distributionSummaryPth); err != nil {
+		log.Warnf("Failed to check if %s exists, error: %s", distributionSummaryPlistName, err)
+	} else if exist {
+		fmt.Println()
+		log.Infof("Distribution summary:")
+
+		if err := printDistributionSummary(distributionSummaryPth); err != nil {
+			log.Warnf("%s", err)
 		}
+	}
+	fmt.Println()
 
-		log.Donef("The app path is now available in the Environment Variable: %s (value: %s)", bitriseAppPathEnvKey, appPath)
-		return
+	startPhase("artifacts")
+
+	pattern := filepath.Join(tmpDir, "*.app")
+	apps, err := filepath.Glob(pattern)
+	if err != nil {
+		fail(failureExport, "Failed to find app, with pattern: %s, error: %s", pattern, err)
 	}
 
 	pattern = filepath.Join(tmpDir, "*.pkg")
 	pkgs, err := filepath.Glob(pattern)
 	if err != nil {
-		fail("Failed to find pkg, with pattern: %s, error: %s", pattern, err)
+		fail(failureExport, "Failed to find pkg, with pattern: %s, error: %s", pattern, err)
+	}
+
+	if len(apps) == 0 && len(pkgs) == 0 {
+		fail(failureExport, "No app nor pkg output generated")
+	}
+
+	checkArtifactKinds(exportedMethod, apps, pkgs)
+
+	mainAppName := filepath.Base(archive.Application.Path)
+
+	if len(apps) > 0 {
+		log.Infof("Exporting apps...")
+
+		apps = mainFirst(apps, mainAppName)
+		if err := exportApps(apps, namer, appFormat, zipOptions...); err != nil {
+			fail(failureOutput, "Failed to export apps, error: %s", err)
+		}
+		fmt.Println()
 	}
 
 	if len(pkgs) > 0 {
-		if err := utils.ExportOutputFile(pkgs[0], pkgPath, bitrisePKGPathEnvKey); err != nil {
-			fail("Failed to export %s, error: %s", bitrisePKGPathEnvKey, err)
+		log.Infof("Exporting installer packages...")
+
+		pkgs = mainFirst(pkgs, strings.TrimSuffix(mainAppName, filepath.Ext(mainAppName))+".pkg")
+		if err := exportPkgs(pkgs, namer); err != nil {
+			fail(failureOutput, "Failed to export installer packages, error: %s", err)
 		}
+		fmt.Println()
+	}
 
-		log.Donef("The pkg path is now available in the Environment Variable: %s (value: %s)", bitrisePKGPathEnvKey, appPath)
-	} else {
-		fail("No app nor pkg output generated")
+	{
+		log.Infof("Writing artifact metadata...")
+
+		var appsMetadataPth, pkgsMetadataPth string
+		if len(apps) > 0 {
+			appsMetadataPth, err = writeAppsMetadata(apps, namer, appFormat, string(exportedMethod), configs.TeamID)
+			if err != nil {
+				fail(failureOutput, "Failed to write artifact metadata, error: %s", err)
+			}
+		}
+		if len(pkgs) > 0 {
+			pkgsMetadataPth, err = writePkgsMetadata(pkgs, namer, archive.AppPaths(), distributionSummaryPth, string(exportedMethod), configs.TeamID)
+			if err != nil {
+				fail(failureOutput, "Failed to write artifact metadata, error: %s", err)
+			}
+		}
+
+		// the main artifact is the one the export method normally produces
+		metadataPth := appsMetadataPth
+		if metadataPth == "" || (pkgsMetadataPth != "" && expectedArtifactKind(exportedMethod) == ".pkg") {
+			metadataPth = pkgsMetadataPth
+		}
+		if err := exportArtifactMetadata(metadataPth); err != nil {
+			fail(failureOutput, "Failed to export artifact metadata, error: %s", err)
+		}
+		fmt.Println()
+	}
+
+	if configs.SparkleAppcast == "yes" {
+		if exportedMethod != exportoptions.MethodDeveloperID || len(apps) == 0 {
+			log.Warnf("Sparkle appcast is generated for developer-id app exports only, skipping...")
+		} else {
+			startPhase("sparkle")
+			log.Infof("Generating Sparkle appcast...")
+
+			sparkleConfig := sparkleAppcastConfig{
+				AppcastPath:             configs.SparkleAppcastPath,
+				DownloadURLTemplate:     configs.SparkleDownloadURLTemplate,
+				ReleaseNotesURLTemplate: configs.SparkleReleaseNotesURLTemplate,
+				PrivateKeyPath:          configs.SparklePrivateKeyPath,
+			}
+			if err := exportSparkleAppcast(sparkleConfig, archive.Application.InfoPlist, namer.artifactPath(".app.zip"), appcastPath); err != nil {
+				fail(failureOutput, "Failed to generate Sparkle appcast, error: %s", err)
+			}
+		}
 	}
 }
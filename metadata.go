@@ -0,0 +1,363 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-io/go-xcode/plistutil"
+	"github.com/bitrise-io/go-xcode/profileutil"
+	"github.com/bitrise-steplib/steps-export-xcarchive-mac/utils"
+)
+
+const bitriseArtifactMetadataPathEnvKey = "BITRISE_ARTIFACT_METADATA_PATH"
+
+// metadataExt is appended to the artifact's path to get the path of its metadata.
+const metadataExt = ".metadata.json"
+
+// artifactMetadata describes an exported artifact for the deploy and release tooling.
+type artifactMetadata struct {
+	Name                 string            `json:"name"`
+	Kind                 string            `json:"kind"`
+	BundleID             string            `json:"bundle_id,omitempty"`
+	Version              string            `json:"version,omitempty"`
+	Build                string            `json:"build,omitempty"`
+	MinimumSystemVersion string            `json:"minimum_system_version,omitempty"`
+	Size                 int64             `json:"size"`
+	Architectures        []string          `json:"architectures,omitempty"`
+	TeamID               string            `json:"team_id,omitempty"`
+	ExportMethod         string            `json:"export_method,omitempty"`
+	ProvisioningProfiles []profileMetadata `json:"provisioning_profiles,omitempty"`
+}
+
+// profileMetadata is a provisioning profile used to sign a bundle of the artifact.
+type profileMetadata struct {
+	Name           string    `json:"name"`
+	UUID           string    `json:"uuid,omitempty"`
+	BundleID       string    `json:"bundle_id,omitempty"`
+	ExpirationDate time.Time `json:"expiration_date"`
+}
+
+// newArtifactMetadata collects the metadata of the artifact from the bundle (app or framework) or binary it was created from.
+func newArtifactMetadata(artifactPth string, kind productKind, sourcePth, exportMethod, teamID string) (artifactMetadata, error) {
+	size, err := pathSize(artifactPth)
+	if err != nil {
+		return artifactMetadata{}, fmt.Errorf("failed to get the size of %s: %s", artifactPth, err)
+	}
+
+	metadata := artifactMetadata{
+		Name:         filepath.Base(artifactPth),
+		Kind:         string(kind),
+		Size:         size,
+		TeamID:       teamID,
+		ExportMethod: exportMethod,
+	}
+
+	executablePth := sourcePth
+	if kind != productKindBinary {
+		infoPlistPth := filepath.Join(sourcePth, "Contents", "Info.plist")
+		if kind == productKindFramework {
+			infoPlistPth = filepath.Join(sourcePth, "Resources", "Info.plist")
+		}
+
+		infoPlist, err := plistutil.NewPlistDataFromFile(infoPlistPth)
+		if err != nil {
+			return artifactMetadata{}, fmt.Errorf("failed to parse Info.plist (%s): %s", infoPlistPth, err)
+		}
+
+		metadata.BundleID, _ = infoPlist.GetString("CFBundleIdentifier")
+		metadata.Version, _ = infoPlist.GetString("CFBundleShortVersionString")
+		metadata.Build, _ = infoPlist.GetString("CFBundleVersion")
+		metadata.MinimumSystemVersion, _ = infoPlist.GetString("LSMinimumSystemVersion")
+
+		executableName, _ := infoPlist.GetString("CFBundleExecutable")
+		executablePth = filepath.Join(sourcePth, "Contents", "MacOS", executableName)
+		if kind == productKindFramework {
+			executablePth = filepath.Join(sourcePth, executableName)
+		}
+	}
+
+	if isMachOFile, err := isMachO(executablePth); err != nil {
+		log.Warnf("Failed to read executable (%s), error: %s", executablePth, err)
+	} else if isMachOFile {
+		slices, err := machOSlices(executablePth)
+		if err != nil {
+			return artifactMetadata{}, err
+		}
+		for _, slice := range slices {
+			metadata.Architectures = append(metadata.Architectures, slice.Arch)
+		}
+	}
+
+	if kind == productKindApp {
+		profiles, profileTeamID, err := embeddedProfiles(sourcePth)
+		if err != nil {
+			return artifactMetadata{}, err
+		}
+
+		metadata.ProvisioningProfiles = profiles
+		if metadata.TeamID == "" {
+			metadata.TeamID = profileTeamID
+		}
+	}
+
+	return metadata, nil
+}
+
+// embeddedProfiles returns the provisioning profiles embedded into the app and its extensions, and the app's team.
+func embeddedProfiles(appPth string) ([]profileMetadata, string, error) {
+	pattern := filepath.Join(pathutil.EscapeGlobPath(appPth), "Contents", "PlugIns", "*.appex")
+	extensionPths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to search for extensions using pattern: %s, error: %s", pattern, err)
+	}
+
+	var profiles []profileMetadata
+	teamID := ""
+	for _, bundlePth := range append([]string{appPth}, extensionPths...) {
+		profilePth := filepath.Join(bundlePth, "Contents", "embedded.provisionprofile")
+		if exist, err := pathutil.IsPathExists(profilePth); err != nil {
+			return nil, "", fmt.Errorf("failed to check if profile exists at: %s, error: %s", profilePth, err)
+		} else if !exist {
+			continue
+		}
+
+		profile, err := profileutil.NewProvisioningProfileInfoFromFile(profilePth)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to parse profile (%s): %s", profilePth, err)
+		}
+
+		if teamID == "" {
+			teamID = profile.TeamID
+		}
+
+		profiles = append(profiles, profileMetadata{
+			Name:           profile.Name,
+			UUID:           profile.UUID,
+			BundleID:       profile.BundleID,
+			ExpirationDate: profile.ExpirationDate,
+		})
+	}
+
+	return profiles, teamID, nil
+}
+
+// distributionSummaryProfiles returns the provisioning profiles and the team the artifact was signed with
+// according to the DistributionSummary.plist written by xcodebuild.
+func distributionSummaryProfiles(summaryPth, artifactName string) ([]profileMetadata, string, error) {
+	summary, err := plistutil.NewPlistDataFromFile(summaryPth)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse %s: %s", distributionSummaryPlistName, err)
+	}
+
+	bundles, _ := summary.GetMapStringInterfaceArray(artifactName)
+
+	var profiles []profileMetadata
+	teamID := ""
+	for len(bundles) > 0 {
+		bundle := bundles[0]
+		bundles = bundles[1:]
+
+		if team, found := bundle.GetMapStringInterface("team"); found && teamID == "" {
+			teamID, _ = team.GetString("id")
+		}
+
+		if profile, found := bundle.GetMapStringInterface("profile"); found {
+			name, _ := profile.GetString("name")
+			uuid, _ := profile.GetString("UUID")
+			expiry, _ := profile.GetTime("dateExpires")
+			profiles = append(profiles, profileMetadata{Name: name, UUID: uuid, ExpirationDate: expiry})
+		}
+
+		if embeddedBinaries, found := bundle.GetMapStringInterfaceArray("embeddedBinaries"); found {
+			bundles = append(bundles, embeddedBinaries...)
+		}
+	}
+
+	return profiles, teamID, nil
+}
+
+// pathSize returns the size of the file, or the total size of the files in the dir.
+func pathSize(pth string) (int64, error) {
+	var size int64
+	err := filepath.Walk(pth, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// writeArtifactMetadata writes the metadata next to the artifact and returns the metadata's path.
+func writeArtifactMetadata(artifactPth string, metadata artifactMetadata) (string, error) {
+	metadataPth := artifactPth + metadataExt
+	// the metadata files are listed in SHA256SUMS too
+	if err := utils.WriteJSONArtifact(metadataPth, metadata); err != nil {
+		return "", fmt.Errorf("failed to write metadata of %s: %s", filepath.Base(artifactPth), err)
+	}
+
+	log.Printf("- %s", filepath.Base(metadataPth))
+	return metadataPth, nil
+}
+
+// exportArtifactMetadata exports the path of the main artifact's metadata.
+func exportArtifactMetadata(metadataPth string) error {
+	if err := utils.ExportOutputFile(metadataPth, metadataPth, bitriseArtifactMetadataPathEnvKey); err != nil {
+		return fmt.Errorf("failed to export %s: %s", bitriseArtifactMetadataPathEnvKey, err)
+	}
+
+	log.Donef("The artifact metadata path is now available in the Environment Variable: %s (value: %s)", bitriseArtifactMetadataPathEnvKey, metadataPth)
+	return nil
+}
+
+// writeAppsMetadata writes the metadata of every exported app bundle and zip, and returns the path of the main app's metadata:
+// the app bundle's metadata if the format includes directories, the zip's metadata otherwise.
+func writeAppsMetadata(appPths []string, namer artifactNamer, format appOutputFormat, exportMethod, teamID string) (string, error) {
+	mainMetadataPth := ""
+	for i, appPth := range appPths {
+		dirPth, zipPth := appDestinationPaths(namer, i, appPth)
+
+		var artifactPths []string
+		if format.directory() {
+			artifactPths = append(artifactPths, dirPth)
+		}
+		if format.zip() {
+			artifactPths = append(artifactPths, zipPth)
+		}
+
+		for _, artifactPth := range artifactPths {
+			metadata, err := newArtifactMetadata(artifactPth, productKindApp, appPth, exportMethod, teamID)
+			if err != nil {
+				return "", err
+			}
+
+			metadataPth, err := writeArtifactMetadata(artifactPth, metadata)
+			if err != nil {
+				return "", err
+			}
+
+			if mainMetadataPth == "" {
+				mainMetadataPth = metadataPth
+			}
+		}
+	}
+
+	return mainMetadataPth, nil
+}
+
+// pkgSourceApp returns the archived app the installer package was created from: the app of the same name,
+// or the main app (the first of appPths) for the main package. It returns an empty path if no app matches.
+func pkgSourceApp(i int, pkgPth string, appPths []string) string {
+	name := strings.TrimSuffix(filepath.Base(pkgPth), filepath.Ext(pkgPth))
+	for _, appPth := range appPths {
+		if strings.TrimSuffix(filepath.Base(appPth), filepath.Ext(appPth)) == name {
+			return appPth
+		}
+	}
+	if i == 0 && len(appPths) > 0 {
+		return appPths[0]
+	}
+	return ""
+}
+
+// writePkgsMetadata writes the metadata of every exported installer package, and returns the path of the main package's metadata.
+// The bundle info is read from the archived app of the package (see pkgSourceApp), it is left empty if there is no such app,
+// the signing info is read from the distribution summary if available.
+func writePkgsMetadata(pkgPths []string, namer artifactNamer, appPths []string, distributionSummaryPth, exportMethod, teamID string) (string, error) {
+	summaryExist, err := pathutil.IsPathExists(distributionSummaryPth)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if %s exists: %s", distributionSummaryPlistName, err)
+	}
+
+	mainMetadataPth := ""
+	for i, pkgPth := range pkgPths {
+		artifactPth := pkgDestinationPath(namer, i, pkgPth)
+
+		var metadata artifactMetadata
+		if appPth := pkgSourceApp(i, pkgPth, appPths); appPth != "" {
+			metadata, err = newArtifactMetadata(artifactPth, productKindApp, appPth, exportMethod, teamID)
+			if err != nil {
+				return "", err
+			}
+		} else {
+			size, err := pathSize(artifactPth)
+			if err != nil {
+				return "", fmt.Errorf("failed to get the size of %s: %s", artifactPth, err)
+			}
+			metadata = artifactMetadata{Name: filepath.Base(artifactPth), Size: size, TeamID: teamID, ExportMethod: exportMethod}
+		}
+		metadata.Kind = "pkg"
+		metadata.ProvisioningProfiles = nil
+
+		if summaryExist {
+			profiles, summaryTeamID, err := distributionSummaryProfiles(distributionSummaryPth, filepath.Base(pkgPth))
+			if err != nil {
+				return "", err
+			}
+
+			metadata.ProvisioningProfiles = profiles
+			if summaryTeamID != "" {
+				metadata.TeamID = summaryTeamID
+			}
+		}
+
+		metadataPth, err := writeArtifactMetadata(artifactPth, metadata)
+		if err != nil {
+			return "", err
+		}
+
+		if i == 0 {
+			mainMetadataPth = metadataPth
+		}
+	}
+
+	return mainMetadataPth, nil
+}
+
+// writeProductsMetadata writes the metadata of the zip containing the command-line tools or frameworks, and returns its path.
+// The bundle info is only set if the zip contains a single product, the architectures of every product are listed.
+func writeProductsMetadata(kind productKind, productPths []string, namer artifactNamer, teamID string) (string, error) {
+	artifactPth := productDestinationPath(namer, kind)
+
+	var metadata artifactMetadata
+	var architectures []string
+	for _, productPth := range productPths {
+		productMetadata, err := newArtifactMetadata(artifactPth, kind, productPth, "", teamID)
+		if err != nil {
+			return "", err
+		}
+
+		if len(productPths) == 1 {
+			metadata = productMetadata
+		} else {
+			metadata = artifactMetadata{Name: productMetadata.Name, Kind: productMetadata.Kind, Size: productMetadata.Size, TeamID: teamID}
+		}
+		architectures = append(architectures, productMetadata.Architectures...)
+	}
+	metadata.Architectures = sortedArchitectures(architectures)
+
+	return writeArtifactMetadata(artifactPth, metadata)
+}
+
+// sortedArchitectures returns the architectures in lexical order without duplicates.
+func sortedArchitectures(architectures []string) []string {
+	seen := map[string]bool{}
+	var sorted []string
+	for _, arch := range architectures {
+		if !seen[arch] {
+			seen[arch] = true
+			sorted = append(sorted, arch)
+		}
+	}
+	sort.Strings(sorted)
+	return sorted
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-xcode/plistutil"
+)
+
+const maxOutputNameLength = 200
+
+var outputNamePlaceholderRegexp = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// outputNamePlaceholders lists the placeholders supported by the output_name_template input.
+var outputNamePlaceholders = []string{"archive_name", "scheme", "bundle_id", "version", "build", "method", "team_id", "date"}
+
+// validateOutputNameTemplate checks that the template uses known placeholders only.
+func validateOutputNameTemplate(template string) error {
+	for _, match := range outputNamePlaceholderRegexp.FindAllStringSubmatch(template, -1) {
+		if !isOutputNamePlaceholder(match[1]) {
+			return fmt.Errorf("unknown placeholder %s, available placeholders: {%s}", match[0], strings.Join(outputNamePlaceholders, "}, {"))
+		}
+	}
+	return nil
+}
+
+func isOutputNamePlaceholder(name string) bool {
+	for _, placeholder := range outputNamePlaceholders {
+		if name == placeholder {
+			return true
+		}
+	}
+	return false
+}
+
+// newOutputNameValues collects the placeholder values from the archive's and the main application's Info.plist.
+// The application values take precedence, as the main application might be selected by the user.
+func newOutputNameValues(archiveName string, archiveInfoPlist plistutil.PlistData, appInfoPlist plistutil.PlistData, exportMethod, teamID string, now time.Time) map[string]string {
+	values := map[string]string{
+		"archive_name": archiveName,
+		"method":       exportMethod,
+		"date":         now.Format("2006-01-02"),
+	}
+
+	values["scheme"], _ = archiveInfoPlist.GetString("SchemeName")
+
+	if properties, found := archiveInfoPlist.GetMapStringInterface("ApplicationProperties"); found {
+		values["bundle_id"], _ = properties.GetString("CFBundleIdentifier")
+		values["version"], _ = properties.GetString("CFBundleShortVersionString")
+		values["build"], _ = properties.GetString("CFBundleVersion")
+		values["team_id"], _ = properties.GetString("Team")
+	}
+
+	for key, plistKey := range map[string]string{"bundle_id": "CFBundleIdentifier", "version": "CFBundleShortVersionString", "build": "CFBundleVersion"} {
+		if value, found := appInfoPlist.GetString(plistKey); found && value != "" {
+			values[key] = value
+		}
+	}
+
+	if teamID != "" {
+		values["team_id"] = teamID
+	}
+
+	return values
+}
+
+// renderOutputName replaces the placeholders of the template and validates the result as a file name.
+func renderOutputName(template string, values map[string]string) (string, error) {
+	var missing []string
+	name := outputNamePlaceholderRegexp.ReplaceAllStringFunc(template, func(placeholder string) string {
+		key := strings.Trim(placeholder, "{}")
+		value := values[key]
+		if value == "" {
+			missing = append(missing, placeholder)
+		}
+		return value
+	})
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("no value found for placeholder(s): %s", strings.Join(missing, ", "))
+	}
+
+	if err := validateFileName(name); err != nil {
+		return "", fmt.Errorf("invalid output name (%s): %s", name, err)
+	}
+
+	return name, nil
+}
+
+// validateFileName checks that the name can be used as a single path component on every file system.
+func validateFileName(name string) error {
+	switch {
+	case strings.TrimSpace(name) == "":
+		return fmt.Errorf("empty name")
+	case name == "." || name == "..":
+		return fmt.Errorf("relative path component")
+	case strings.HasPrefix(name, "."):
+		return fmt.Errorf("hidden file name")
+	case len(name) > maxOutputNameLength:
+		return fmt.Errorf("longer than %d characters", maxOutputNameLength)
+	}
+
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return fmt.Errorf("contains invalid character: %q", r)
+		}
+	}
+
+	return nil
+}
+
+// artifactNamer creates the paths of the step's outputs in the deploy dir.
+type artifactNamer struct {
+	deployDir string
+	baseName  string
+	// prefixAuxiliary is set when a custom name template is used, to prefix the additional outputs
+	// (export options, logs, reports) by the base name too, so that multiple exports do not overwrite each other.
+	prefixAuxiliary bool
+}
+
+// artifactPath returns the path of a main artifact: the base name with the given extension.
+func (n artifactNamer) artifactPath(ext string) string {
+	return filepath.Join(n.deployDir, n.baseName+ext)
+}
+
+// auxiliaryPath returns the path of an additional output, which has a fixed name by default.
+func (n artifactNamer) auxiliaryPath(name string) string {
+	if n.prefixAuxiliary {
+		name = n.baseName + "." + name
+	}
+	return filepath.Join(n.deployDir, name)
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-io/go-xcode/exportoptions"
+	"github.com/bitrise-steplib/steps-export-xcarchive-mac/utils"
+)
+
+const (
+	bitriseAppDirPathEnvKey    = "BITRISE_APP_DIR_PATH"
+	bitriseAppZipPathEnvKey    = "BITRISE_APP_ZIP_PATH"
+	bitrisePKGPathListEnvKey   = "BITRISE_PKG_PATH_LIST"
+	bitriseBinaryPathEnvKey    = "BITRISE_BINARY_PATH"
+	bitriseFrameworkPathEnvKey = "BITRISE_FRAMEWORK_PATH"
+)
+
+// productKind is the kind of the products an archive contains.
+type productKind string
+
+const (
+	productKindApp       productKind = "app"
+	productKindBinary    productKind = "binary"
+	productKindFramework productKind = "framework"
+)
+
+// productPatterns lists the product locations, relative to the archive, by product kind in the order of detection.
+var productPatterns = []struct {
+	kind    productKind
+	pattern string
+}{
+	{kind: productKindApp, pattern: "Products/Applications/*.app"},
+	{kind: productKindBinary, pattern: "Products/usr/local/bin/*"},
+	{kind: productKindFramework, pattern: "Products/Library/Frameworks/*.framework"},
+}
+
+// detectProducts returns the kind and the paths of the products in the archive.
+func detectProducts(archivePath string) (productKind, []string, error) {
+	for _, productPattern := range productPatterns {
+		pattern := filepath.Join(pathutil.EscapeGlobPath(archivePath), productPattern.pattern)
+		pths, err := filepath.Glob(pattern)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to search for products using pattern: %s, error: %s", pattern, err)
+		}
+
+		if productPattern.kind == productKindBinary {
+			pths, err = filterRegularFiles(pths)
+			if err != nil {
+				return "", nil, err
+			}
+		}
+
+		if len(pths) > 0 {
+			return productPattern.kind, pths, nil
+		}
+	}
+
+	return "", nil, fmt.Errorf("failed to find any app, command-line tool or framework in the archive: %s", archivePath)
+}
+
+func filterRegularFiles(pths []string) ([]string, error) {
+	var files []string
+	for _, pth := range pths {
+		info, err := os.Lstat(pth)
+		if err != nil {
+			return nil, err
+		}
+		if info.Mode().IsRegular() {
+			files = append(files, pth)
+		}
+	}
+	return files, nil
+}
+
+// exportProducts zips the command-line tools or frameworks of the archive into the deploy dir.
+// These products are signed when archiving, xcodebuild can not export them.
+func exportProducts(kind productKind, productPths []string, namer artifactNamer, zipOptions ...utils.ZipOption) error {
+	var envKey string
+	switch kind {
+	case productKindBinary:
+		envKey = bitriseBinaryPathEnvKey
+	case productKindFramework:
+		envKey = bitriseFrameworkPathEnvKey
+	default:
+		return fmt.Errorf("unsupported product kind: %s", kind)
+	}
+	destinationPth := productDestinationPath(namer, kind)
+
+	for _, pth := range productPths {
+		log.Printf("- %s", filepath.Base(pth))
+	}
+
+	if err := utils.ExportOutputDirsAsZip(productPths, destinationPth, envKey, zipOptions...); err != nil {
+		return fmt.Errorf("failed to export %s: %s", envKey, err)
+	}
+
+	log.Donef("The %s path is now available in the Environment Variable: %s (value: %s)", kind, envKey, destinationPth)
+
+	return nil
+}
+
+// appOutputFormat is the format the applications are exported in.
+type appOutputFormat string
+
+const (
+	appOutputFormatZip       appOutputFormat = "zip"
+	appOutputFormatDirectory appOutputFormat = "directory"
+	appOutputFormatBoth      appOutputFormat = "both"
+)
+
+func parseAppOutputFormat(format string) (appOutputFormat, error) {
+	switch appOutputFormat(format) {
+	case appOutputFormatZip, appOutputFormatDirectory, appOutputFormatBoth:
+		return appOutputFormat(format), nil
+	default:
+		return "", fmt.Errorf("invalid app output format: %s, available formats: %s, %s, %s", format, appOutputFormatZip, appOutputFormatDirectory, appOutputFormatBoth)
+	}
+}
+
+func (f appOutputFormat) zip() bool {
+	return f == appOutputFormatZip || f == appOutputFormatBoth
+}
+
+func (f appOutputFormat) directory() bool {
+	return f == appOutputFormatDirectory || f == appOutputFormatBoth
+}
+
+// productDestinationPath returns the path of the zip containing the command-line tools or frameworks.
+func productDestinationPath(namer artifactNamer, kind productKind) string {
+	if kind == productKindFramework {
+		return namer.artifactPath(".framework.zip")
+	}
+	return namer.artifactPath(".zip")
+}
+
+// exportApps copies the applications into the deploy dir as app bundles and/or zips them.
+// The first one is the main application, which is exported by the artifact name, the others are exported by their own name.
+// BITRISE_APP_PATH and BITRISE_APP_PATH_LIST point to the app bundles if the format includes directories, to the zips otherwise.
+func exportApps(appPths []string, namer artifactNamer, format appOutputFormat, zipOptions ...utils.ZipOption) error {
+	if len(appPths) == 0 {
+		return fmt.Errorf("no app to export")
+	}
+
+	mainAppDirPth, mainAppZipPth := appDestinationPaths(namer, 0, appPths[0])
+
+	var dirPths, zipPths []string
+	for i, appPth := range appPths {
+		dirPth, zipPth := appDestinationPaths(namer, i, appPth)
+
+		if format.directory() {
+			if err := os.RemoveAll(dirPth); err != nil {
+				return fmt.Errorf("failed to remove previous app (%s): %s", dirPth, err)
+			}
+			if err := command.CopyDir(appPth, dirPth, true); err != nil {
+				return fmt.Errorf("failed to copy app (%s): %s", appPth, err)
+			}
+
+			log.Printf("- %s: %s", filepath.Base(appPth), dirPth)
+			dirPths = append(dirPths, dirPth)
+		}
+
+		if format.zip() {
+			if err := utils.CopyDirsAsZip([]string{appPth}, zipPth, zipOptions...); err != nil {
+				return fmt.Errorf("failed to zip app (%s): %s", appPth, err)
+			}
+
+			log.Printf("- %s: %s", filepath.Base(appPth), zipPth)
+			zipPths = append(zipPths, zipPth)
+		}
+	}
+
+	if format.directory() {
+		if err := utils.ExportOutputDir(mainAppDirPth, mainAppDirPth, bitriseAppDirPathEnvKey); err != nil {
+			return fmt.Errorf("failed to export %s: %s", bitriseAppDirPathEnvKey, err)
+		}
+
+		log.Donef("The app bundle path is now available in the Environment Variable: %s (value: %s)", bitriseAppDirPathEnvKey, mainAppDirPth)
+	}
+
+	if format.zip() {
+		if err := utils.ExportOutputFile(mainAppZipPth, mainAppZipPth, bitriseAppZipPathEnvKey); err != nil {
+			return fmt.Errorf("failed to export %s: %s", bitriseAppZipPathEnvKey, err)
+		}
+
+		log.Donef("The app zip path is now available in the Environment Variable: %s (value: %s)", bitriseAppZipPathEnvKey, mainAppZipPth)
+	}
+
+	mainAppPth, appListPths := mainAppZipPth, zipPths
+	if format.directory() {
+		mainAppPth, appListPths = mainAppDirPth, dirPths
+	}
+
+	if err := utils.ExportOutputFile(mainAppPth, mainAppPth, bitriseAppPathEnvKey); err != nil {
+		return fmt.Errorf("failed to export %s: %s", bitriseAppPathEnvKey, err)
+	}
+
+	if err := utils.ExportOutputList(appListPths, bitriseAppPathListEnvKey); err != nil {
+		return fmt.Errorf("failed to export %s: %s", bitriseAppPathListEnvKey, err)
+	}
+
+	log.Donef("The app path is now available in the Environment Variable: %s (value: %s)", bitriseAppPathEnvKey, mainAppPth)
+	log.Donef("The app path list is now available in the Environment Variable: %s", bitriseAppPathListEnvKey)
+
+	return nil
+}
+
+// appDestinationPaths returns the app bundle's and the zip's path of the i-th exported app,
+// the main app (the first one) is exported by the artifact name, the others by their own name.
+func appDestinationPaths(namer artifactNamer, i int, appPth string) (string, string) {
+	if i == 0 {
+		return namer.artifactPath(".app"), namer.artifactPath(".app.zip")
+	}
+	return namer.auxiliaryPath(filepath.Base(appPth)), namer.auxiliaryPath(filepath.Base(appPth) + ".zip")
+}
+
+// pkgDestinationPath returns the path of the i-th exported installer package,
+// the main package (the first one) is exported by the artifact name, the others by their own name.
+func pkgDestinationPath(namer artifactNamer, i int, pkgPth string) string {
+	if i == 0 {
+		return namer.artifactPath(".pkg")
+	}
+	return namer.auxiliaryPath(filepath.Base(pkgPth))
+}
+
+// exportPkgs copies the installer packages into the deploy dir, the first one is the main package,
+// which is exported by the artifact name, the others are exported by their own name.
+func exportPkgs(pkgPths []string, namer artifactNamer) error {
+	if len(pkgPths) == 0 {
+		return fmt.Errorf("no pkg to export")
+	}
+
+	mainPkgDestinationPth := pkgDestinationPath(namer, 0, pkgPths[0])
+
+	var destinationPths []string
+	for i, pkgPth := range pkgPths {
+		destinationPth := pkgDestinationPath(namer, i, pkgPth)
+
+		if err := utils.CopyFile(pkgPth, destinationPth); err != nil {
+			return fmt.Errorf("failed to copy pkg (%s): %s", pkgPth, err)
+		}
+
+		log.Printf("- %s: %s", filepath.Base(pkgPth), destinationPth)
+		destinationPths = append(destinationPths, destinationPth)
+	}
+
+	if err := utils.ExportOutputFile(mainPkgDestinationPth, mainPkgDestinationPth, bitrisePKGPathEnvKey); err != nil {
+		return fmt.Errorf("failed to export %s: %s", bitrisePKGPathEnvKey, err)
+	}
+
+	if err := utils.ExportOutputList(destinationPths, bitrisePKGPathListEnvKey); err != nil {
+		return fmt.Errorf("failed to export %s: %s", bitrisePKGPathListEnvKey, err)
+	}
+
+	log.Donef("The pkg path is now available in the Environment Variable: %s (value: %s)", bitrisePKGPathEnvKey, mainPkgDestinationPth)
+	log.Donef("The pkg path list is now available in the Environment Variable: %s", bitrisePKGPathListEnvKey)
+
+	return nil
+}
+
+// expectedArtifactKind returns the artifact extension xcodebuild normally exports by the export method:
+// installer packages for the App Store, applications otherwise.
+func expectedArtifactKind(method exportoptions.Method) string {
+	if method == exportoptions.MethodAppStore {
+		return ".pkg"
+	}
+	return ".app"
+}
+
+// checkArtifactKinds warns if the exported artifacts do not match the kind the export method normally produces.
+func checkArtifactKinds(method exportoptions.Method, appPths, pkgPths []string) {
+	expected := expectedArtifactKind(method)
+	switch {
+	case expected == ".pkg" && len(pkgPths) == 0:
+		log.Warnf("The %s export method normally produces an installer package, but only app(s) were exported", method)
+	case expected == ".app" && len(appPths) == 0:
+		log.Warnf("The %s export method normally produces an application, but only installer package(s) were exported", method)
+	case len(appPths) > 0 && len(pkgPths) > 0:
+		log.Warnf("Both app(s) and installer package(s) were exported by the %s export method", method)
+	}
+}
+
+// mainFirst moves the path with the given name to the front of the list.
+func mainFirst(pths []string, mainName string) []string {
+	sorted := []string{}
+	for _, pth := range pths {
+		if filepath.Base(pth) == mainName {
+			sorted = append([]string{pth}, sorted...)
+		} else {
+			sorted = append(sorted, pth)
+		}
+	}
+	return sorted
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-io/go-xcode/plistutil"
+	"github.com/bitrise-steplib/steps-export-xcarchive-mac/utils"
+)
+
+const bitriseSparkleAppcastPathEnvKey = "BITRISE_SPARKLE_APPCAST_PATH"
+
+const sparkleNamespace = "http://www.andymatuschak.org/xml-namespaces/sparkle"
+
+// sparkleItemRegexp matches the items of an appcast, used to replace the item of the same version.
+var sparkleItemRegexp = regexp.MustCompile(`(?s)[ \t]*<item>.*?</item>[ \t]*\n?`)
+
+// sparkleAppcastConfig configures the appcast item of an export.
+type sparkleAppcastConfig struct {
+	// AppcastPath is the existing appcast to update, a new appcast is created if empty.
+	AppcastPath string
+	// DownloadURLTemplate is the URL of the archive, supports the {file_name}, {version} and {build} placeholders.
+	DownloadURLTemplate string
+	// ReleaseNotesURLTemplate is the release notes link, supports the same placeholders as DownloadURLTemplate.
+	ReleaseNotesURLTemplate string
+	// PrivateKeyPath is the path of the EdDSA private key file exported by Sparkle's generate_keys.
+	PrivateKeyPath string
+}
+
+type sparkleEnclosure struct {
+	URL         string `xml:"url,attr"`
+	Length      int64  `xml:"length,attr"`
+	Type        string `xml:"type,attr"`
+	EdSignature string `xml:"sparkle:edSignature,attr"`
+}
+
+type sparkleItem struct {
+	XMLName              xml.Name         `xml:"item"`
+	Title                string           `xml:"title"`
+	PubDate              string           `xml:"pubDate"`
+	Version              string           `xml:"sparkle:version"`
+	ShortVersionString   string           `xml:"sparkle:shortVersionString,omitempty"`
+	MinimumSystemVersion string           `xml:"sparkle:minimumSystemVersion,omitempty"`
+	ReleaseNotesLink     string           `xml:"sparkle:releaseNotesLink,omitempty"`
+	Enclosure            sparkleEnclosure `xml:"enclosure"`
+}
+
+// newSparkleAppcastItem creates the appcast item of the archive file, signed by the private key.
+func newSparkleAppcastItem(config sparkleAppcastConfig, appInfoPlist plistutil.PlistData, archiveFilePth string, privateKey ed25519.PrivateKey, now time.Time) (sparkleItem, error) {
+	version, _ := appInfoPlist.GetString("CFBundleVersion")
+	if version == "" {
+		return sparkleItem{}, fmt.Errorf("CFBundleVersion not found in the app's Info.plist")
+	}
+	shortVersion, _ := appInfoPlist.GetString("CFBundleShortVersionString")
+	minimumSystemVersion, _ := appInfoPlist.GetString("LSMinimumSystemVersion")
+
+	content, err := os.ReadFile(archiveFilePth)
+	if err != nil {
+		return sparkleItem{}, fmt.Errorf("failed to read %s: %s", archiveFilePth, err)
+	}
+
+	urlReplacer := strings.NewReplacer(
+		"{file_name}", filepath.Base(archiveFilePth),
+		"{version}", shortVersion,
+		"{build}", version,
+	)
+
+	title := shortVersion
+	if title == "" {
+		title = version
+	}
+
+	item := sparkleItem{
+		Title:                fmt.Sprintf("Version %s", title),
+		PubDate:              now.UTC().Format(time.RFC1123Z),
+		Version:              version,
+		ShortVersionString:   shortVersion,
+		MinimumSystemVersion: minimumSystemVersion,
+		Enclosure: sparkleEnclosure{
+			URL:         urlReplacer.Replace(config.DownloadURLTemplate),
+			Length:      int64(len(content)),
+			Type:        "application/octet-stream",
+			EdSignature: base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, content)),
+		},
+	}
+	if config.ReleaseNotesURLTemplate != "" {
+		item.ReleaseNotesLink = urlReplacer.Replace(config.ReleaseNotesURLTemplate)
+	}
+
+	return item, nil
+}
+
+// readSparklePrivateKey reads the base64 encoded EdDSA private key exported by Sparkle's generate_keys (-x),
+// both the 32 byte seed and the 64 byte seed and public key formats are supported.
+func readSparklePrivateKey(pth string) (ed25519.PrivateKey, error) {
+	content, err := fileutil.ReadStringFromFile(pth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %s", err)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode private key: %s", err)
+	}
+
+	switch len(key) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(key), nil
+	case ed25519.PrivateKeySize:
+		privateKey := ed25519.NewKeyFromSeed(key[:ed25519.SeedSize])
+		if !privateKey.Equal(ed25519.PrivateKey(key)) {
+			return nil, fmt.Errorf("private key's public part does not match its seed")
+		}
+		return privateKey, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key size: %d bytes", len(key))
+	}
+}
+
+// updateSparkleAppcast adds the item to the appcast, replacing the item of the same version.
+// A new appcast is created if the appcast content is empty.
+func updateSparkleAppcast(appcast, channelTitle string, item sparkleItem) (string, error) {
+	itemContent, err := xml.MarshalIndent(item, "\t\t", "\t")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode appcast item: %s", err)
+	}
+
+	if strings.TrimSpace(appcast) == "" {
+		title := &strings.Builder{}
+		if err := xml.EscapeText(title, []byte(channelTitle)); err != nil {
+			return "", err
+		}
+
+		appcast = xml.Header +
+			fmt.Sprintf(`<rss version="2.0" xmlns:sparkle="%s" xmlns:dc="http://purl.org/dc/elements/1.1/">`, sparkleNamespace) + "\n" +
+			"\t<channel>\n" +
+			fmt.Sprintf("\t\t<title>%s</title>\n", title.String()) +
+			"\t</channel>\n" +
+			"</rss>\n"
+	}
+
+	versionElement := fmt.Sprintf("<sparkle:version>%s</sparkle:version>", item.Version)
+	versionAttribute := fmt.Sprintf(`sparkle:version="%s"`, item.Version)
+	appcast = sparkleItemRegexp.ReplaceAllStringFunc(appcast, func(existing string) string {
+		if strings.Contains(existing, versionElement) || strings.Contains(existing, versionAttribute) {
+			return ""
+		}
+		return existing
+	})
+
+	// the new item is the first one, after the channel's header
+	insertIdx := strings.Index(appcast, "<item>")
+	if insertIdx == -1 {
+		insertIdx = strings.Index(appcast, "</channel>")
+		if insertIdx == -1 {
+			return "", fmt.Errorf("no channel found in the appcast")
+		}
+	}
+	insertIdx = strings.LastIndex(appcast[:insertIdx], "\n") + 1
+
+	return appcast[:insertIdx] + string(itemContent) + "\n" + appcast[insertIdx:], nil
+}
+
+// exportSparkleAppcast creates or updates the appcast with the item of the exported archive file.
+func exportSparkleAppcast(config sparkleAppcastConfig, appInfoPlist plistutil.PlistData, archiveFilePth, appcastPth string) error {
+	privateKey, err := readSparklePrivateKey(config.PrivateKeyPath)
+	if err != nil {
+		return err
+	}
+
+	item, err := newSparkleAppcastItem(config, appInfoPlist, archiveFilePth, privateKey, time.Now())
+	if err != nil {
+		return err
+	}
+
+	appcast := ""
+	if config.AppcastPath != "" {
+		if exist, err := pathutil.IsPathExists(config.AppcastPath); err != nil {
+			return fmt.Errorf("failed to check if appcast exists at: %s, error: %s", config.AppcastPath, err)
+		} else if exist {
+			appcast, err = fileutil.ReadStringFromFile(config.AppcastPath)
+			if err != nil {
+				return fmt.Errorf("failed to read appcast: %s", err)
+			}
+		} else {
+			log.Warnf("Appcast not exists at: %s, creating a new one", config.AppcastPath)
+		}
+	}
+
+	channelTitle, _ := appInfoPlist.GetString("CFBundleName")
+	appcast, err = updateSparkleAppcast(appcast, channelTitle, item)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Appcast item:")
+	log.Printf("- version: %s (%s)", item.ShortVersionString, item.Version)
+	log.Printf("- url: %s", item.Enclosure.URL)
+	log.Printf("- length: %d", item.Enclosure.Length)
+	log.Printf("- edSignature: %s", item.Enclosure.EdSignature)
+
+	if err := utils.ExportOutputFileContent(appcast, appcastPth, bitriseSparkleAppcastPathEnvKey); err != nil {
+		return fmt.Errorf("failed to export %s: %s", bitriseSparkleAppcastPathEnvKey, err)
+	}
+
+	log.Donef("The appcast path is now available in the Environment Variable: %s (value: %s)", bitriseSparkleAppcastPathEnvKey, appcastPth)
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-xcode/plistutil"
+)
+
+func testSparkleItem(version string) sparkleItem {
+	return sparkleItem{
+		Title:   "Version " + version,
+		PubDate: "Mon, 19 Oct 2026 10:00:00 +0000",
+		Version: version,
+		Enclosure: sparkleEnclosure{
+			URL:    "https://example.com/" + version + "/Test.app.zip",
+			Length: 42,
+			Type:   "application/octet-stream",
+		},
+	}
+}
+
+func TestUpdateSparkleAppcastCreatesAppcast(t *testing.T) {
+	appcast, err := updateSparkleAppcast("", "Test & Co", testSparkleItem("100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := xml.Unmarshal([]byte(appcast), new(interface{})); err != nil {
+		t.Fatalf("invalid appcast XML: %s\n%s", err, appcast)
+	}
+	for _, want := range []string{
+		xml.Header,
+		`xmlns:sparkle="` + sparkleNamespace + `"`,
+		"<title>Test &amp; Co</title>",
+		"<sparkle:version>100</sparkle:version>",
+		`url="https://example.com/100/Test.app.zip"`,
+	} {
+		if !strings.Contains(appcast, want) {
+			t.Errorf("appcast does not contain %s\n%s", want, appcast)
+		}
+	}
+	if strings.Index(appcast, "<item>") > strings.Index(appcast, "</channel>") {
+		t.Errorf("item is not in the channel\n%s", appcast)
+	}
+}
+
+func TestUpdateSparkleAppcastReplacesItemOfSameVersion(t *testing.T) {
+	existing := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:sparkle="http://www.andymatuschak.org/xml-namespaces/sparkle">
+	<channel>
+		<title>Test</title>
+		<item>
+			<title>Version 3</title>
+			<sparkle:version>300</sparkle:version>
+			<enclosure url="https://example.com/old/300.zip" length="1" type="application/octet-stream"/>
+		</item>
+		<item>
+			<title>Version 2</title>
+			<enclosure url="https://example.com/old/200.zip" sparkle:version="200" length="1" type="application/octet-stream"/>
+		</item>
+		<item>
+			<title>Version 1</title>
+			<sparkle:version>100</sparkle:version>
+			<enclosure url="https://example.com/old/100.zip" length="1" type="application/octet-stream"/>
+		</item>
+	</channel>
+</rss>
+`
+
+	for _, version := range []string{"300", "200"} {
+		t.Run(version, func(t *testing.T) {
+			appcast, err := updateSparkleAppcast(existing, "Test", testSparkleItem(version))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := strings.Count(appcast, "<item>"); got != 3 {
+				t.Errorf("item count = %d, want 3\n%s", got, appcast)
+			}
+			if strings.Contains(appcast, "https://example.com/old/"+version+".zip") {
+				t.Errorf("old item of version %s is not replaced\n%s", version, appcast)
+			}
+			if !strings.Contains(appcast, "https://example.com/old/100.zip") {
+				t.Errorf("item of another version is removed\n%s", appcast)
+			}
+			if first := strings.Index(appcast, "<item>"); first != strings.Index(appcast, "<item>\n\t\t\t<title>Version "+version+"</title>") {
+				t.Errorf("new item is not the first one\n%s", appcast)
+			}
+		})
+	}
+}
+
+func writeSparkleKey(t *testing.T, key []byte) string {
+	t.Helper()
+
+	pth := filepath.Join(t.TempDir(), "sparkle_private_key")
+	if err := os.WriteFile(pth, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return pth
+}
+
+func TestReadSparklePrivateKeySeed(t *testing.T) {
+	seed := make([]byte, ed25519.SeedSize)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+
+	privateKey, err := readSparklePrivateKey(writeSparkleKey(t, seed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !privateKey.Equal(ed25519.NewKeyFromSeed(seed)) {
+		t.Errorf("private key does not match the seed")
+	}
+}
+
+func TestReadSparklePrivateKeySeedAndPublicKey(t *testing.T) {
+	seed := make([]byte, ed25519.SeedSize)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	want := ed25519.NewKeyFromSeed(seed)
+
+	privateKey, err := readSparklePrivateKey(writeSparkleKey(t, want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !privateKey.Equal(want) {
+		t.Errorf("private key does not match the key file")
+	}
+
+	mismatching := append(append([]byte{}, seed...), make([]byte, ed25519.PublicKeySize)...)
+	if _, err := readSparklePrivateKey(writeSparkleKey(t, mismatching)); err == nil {
+		t.Errorf("no error for a key with mismatching public part")
+	}
+}
+
+func TestNewSparkleAppcastItemSignature(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := []byte("app zip content")
+	archivePth := filepath.Join(t.TempDir(), "Test.app.zip")
+	if err := os.WriteFile(archivePth, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := sparkleAppcastConfig{DownloadURLTemplate: "https://example.com/{version}/{build}/{file_name}"}
+	infoPlist := plistutil.PlistData{"CFBundleVersion": "42", "CFBundleShortVersionString": "1.2.3"}
+	item, err := newSparkleAppcastItem(config, infoPlist, archivePth, privateKey, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(item.Enclosure.EdSignature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ed25519.Verify(publicKey, content, signature) {
+		t.Errorf("signature does not verify the archive content")
+	}
+	if item.Enclosure.Length != int64(len(content)) {
+		t.Errorf("length = %d, want %d", item.Enclosure.Length, len(content))
+	}
+	if want := "https://example.com/1.2.3/42/Test.app.zip"; item.Enclosure.URL != want {
+		t.Errorf("url = %s, want %s", item.Enclosure.URL, want)
+	}
+}
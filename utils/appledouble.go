@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"path"
+	"sort"
+)
+
+// AppleDouble layout as written by copyfile(3), which ditto uses with --sequesterRsrc:
+// the Finder Info entry is extended with an attribute header listing the extended attributes,
+// and the resource fork entry follows the attribute data.
+const (
+	appleDoubleMagic      = 0x00051607
+	appleDoubleVersion    = 0x00020000
+	appleDoubleEntryRsrc  = 2
+	appleDoubleEntryFInfo = 9
+
+	appleDoubleHeaderSize = 50 // magic, version, filler, entry count and 2 entry descriptors
+	finderInfoSize        = 32
+	attrHeaderMagic       = 0x41545452 // 'ATTR'
+	attrHeaderOffset      = appleDoubleHeaderSize + finderInfoSize + 2
+	attrHeaderSize        = 36
+
+	finderInfoXattr   = "com.apple.FinderInfo"
+	resourceForkXattr = "com.apple.ResourceFork"
+
+	macosxDir = "__MACOSX"
+)
+
+// appleDoubleFiller is the filler of the AppleDouble header written by copyfile(3).
+var appleDoubleFiller = []byte("Mac OS X        ")
+
+// appleDoubleName returns the name of the zip entry holding the AppleDouble data of the given entry.
+func appleDoubleName(entryName string) string {
+	dir, base := path.Split(entryName)
+	return path.Join(macosxDir, dir, "._"+base)
+}
+
+// encodeAppleDouble encodes the extended attributes of a file in AppleDouble format.
+// The Finder Info and the resource fork attributes are stored in their own entries.
+func encodeAppleDouble(xattrs map[string][]byte) ([]byte, error) {
+	finderInfo := make([]byte, finderInfoSize)
+	copy(finderInfo, xattrs[finderInfoXattr])
+	resourceFork := xattrs[resourceForkXattr]
+
+	var names []string
+	for name := range xattrs {
+		if name == finderInfoXattr || name == resourceForkXattr {
+			continue
+		}
+		if len(name)+1 > 128 {
+			return nil, fmt.Errorf("extended attribute name too long: %s", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	entriesSize := 0
+	for _, name := range names {
+		entriesSize += attrEntrySize(name)
+	}
+
+	dataStart := attrHeaderOffset + attrHeaderSize + entriesSize
+	dataLength := 0
+	for _, name := range names {
+		dataLength += len(xattrs[name])
+	}
+	totalSize := dataStart + dataLength
+
+	buf := &bytes.Buffer{}
+	write := func(v interface{}) {
+		// writing into a bytes.Buffer can not fail
+		_ = binary.Write(buf, binary.BigEndian, v)
+	}
+
+	// AppleDouble header
+	write(uint32(appleDoubleMagic))
+	write(uint32(appleDoubleVersion))
+	buf.Write(appleDoubleFiller)
+	write(uint16(2))
+	write(uint32(appleDoubleEntryFInfo))
+	write(uint32(appleDoubleHeaderSize))
+	write(uint32(totalSize - appleDoubleHeaderSize))
+	write(uint32(appleDoubleEntryRsrc))
+	write(uint32(totalSize))
+	write(uint32(len(resourceFork)))
+	buf.Write(finderInfo)
+	buf.Write([]byte{0, 0})
+
+	// attribute header
+	write(uint32(attrHeaderMagic))
+	write(uint32(0)) // debug tag
+	write(uint32(totalSize))
+	write(uint32(dataStart))
+	write(uint32(dataLength))
+	buf.Write(make([]byte, 12))
+	write(uint16(0))
+	write(uint16(len(names)))
+
+	// attribute entries
+	offset := dataStart
+	for _, name := range names {
+		write(uint32(offset))
+		write(uint32(len(xattrs[name])))
+		write(uint16(0))
+		write(uint8(len(name) + 1))
+		buf.WriteString(name)
+		buf.Write(make([]byte, attrEntrySize(name)-11-len(name)))
+		offset += len(xattrs[name])
+	}
+
+	// attribute data
+	for _, name := range names {
+		buf.Write(xattrs[name])
+	}
+
+	buf.Write(resourceFork)
+
+	return buf.Bytes(), nil
+}
+
+// attrEntrySize is the 4 byte aligned size of an attribute entry: offset, length, flags, name length and the NULL terminated name.
+func attrEntrySize(name string) int {
+	return (11 + len(name) + 1 + 3) &^ 3
+}
@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// attrDebugTagOffset is the offset of the attribute header's debug tag, copyfile(3) writes the file's inode number into it.
+const attrDebugTagOffset = attrHeaderOffset + 4
+
+// dittoZipPth is the zip created on macOS by:
+//
+//	printf hello > test.txt
+//	xattr -wx com.apple.FinderInfo 5445585474747874000000000000000000000000000000000000000000000000 test.txt
+//	xattr -w com.example.test hello test.txt
+//	ditto -c -k --sequesterRsrc --keepParent test.txt ditto_test.zip
+var dittoZipPth = filepath.Join("testdata", "ditto_test.zip")
+
+// TestEncodeAppleDoubleMatchesDitto compares the encoded attributes with the __MACOSX/._test.txt entry written by ditto.
+func TestEncodeAppleDoubleMatchesDitto(t *testing.T) {
+	if _, err := os.Stat(dittoZipPth); os.IsNotExist(err) {
+		t.Skipf("%s is not available, it has to be created on macOS", dittoZipPth)
+	}
+
+	entries := readZipEntries(t, dittoZipPth)
+	f, ok := entries["__MACOSX/._test.txt"]
+	if !ok {
+		t.Fatalf("__MACOSX/._test.txt not found in %s", dittoZipPth)
+	}
+	want := []byte(readZipEntry(t, f))
+	if len(want) < attrDebugTagOffset+4 {
+		t.Fatalf("__MACOSX/._test.txt is too short: %x", want)
+	}
+	copy(want[attrDebugTagOffset:attrDebugTagOffset+4], make([]byte, 4))
+
+	got, err := encodeAppleDouble(map[string][]byte{
+		finderInfoXattr:    append([]byte("TEXTttxt"), make([]byte, 24)...),
+		"com.example.test": []byte("hello"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Fatalf("encoded AppleDouble differs from the ditto one\ngot:  %x\nwant: %x", got, want)
+	}
+}
+
+func TestAppleDoubleName(t *testing.T) {
+	for name, want := range map[string]string{
+		"Test.app/":                    "__MACOSX/Test.app/._",
+		"Test.app":                     "__MACOSX/._Test.app",
+		"Test.app/Contents/Info.plist": "__MACOSX/Test.app/Contents/._Info.plist",
+		"Test.app/Contents/MacOS/Test": "__MACOSX/Test.app/Contents/MacOS/._Test",
+	} {
+		if got := appleDoubleName(name); got != want {
+			t.Errorf("appleDoubleName(%s) = %s, want %s", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+// ExportedArtifact is a file exported by the step with its checksums.
+type ExportedArtifact struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	EnvKey string `json:"env_key,omitempty"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	SHA512 string `json:"sha512,omitempty"`
+
+	// modTime is the modification time of the file when its checksums were computed.
+	modTime time.Time
+}
+
+var (
+	exportedArtifacts = map[string]ExportedArtifact{}
+	computeSHA512     bool
+)
+
+// SetComputeSHA512 enables SHA-512 checksums along with the SHA-256 ones.
+func SetComputeSHA512(enable bool) {
+	computeSHA512 = enable
+}
+
+// ExportedArtifacts returns the files exported so far, sorted by name.
+func ExportedArtifacts() []ExportedArtifact {
+	var artifacts []ExportedArtifact
+	for _, artifact := range exportedArtifacts {
+		artifacts = append(artifacts, artifact)
+	}
+	sort.Slice(artifacts, func(i, j int) bool {
+		return artifacts[i].Name < artifacts[j].Name
+	})
+	return artifacts
+}
+
+func recordArtifact(artifact ExportedArtifact) {
+	if previous, ok := exportedArtifacts[artifact.Path]; ok && artifact.EnvKey == "" {
+		artifact.EnvKey = previous.EnvKey
+	}
+	exportedArtifacts[artifact.Path] = artifact
+}
+
+// isRecordedArtifact checks if the checksums of the file are recorded, and the file did not change since then.
+func isRecordedArtifact(pth string) (bool, error) {
+	artifact, ok := exportedArtifacts[pth]
+	if !ok {
+		return false, nil
+	}
+
+	info, err := os.Stat(pth)
+	if err != nil {
+		return false, err
+	}
+	return info.Size() == artifact.Size && info.ModTime().Equal(artifact.modTime), nil
+}
+
+func setArtifactEnvKey(pth, envKey string) {
+	if artifact, ok := exportedArtifacts[pth]; ok {
+		artifact.EnvKey = envKey
+		exportedArtifacts[pth] = artifact
+	}
+}
+
+type checksumWriter struct {
+	sha256 hash.Hash
+	sha512 hash.Hash
+	size   int64
+}
+
+func newChecksumWriter() *checksumWriter {
+	w := &checksumWriter{sha256: sha256.New()}
+	if computeSHA512 {
+		w.sha512 = sha512.New()
+	}
+	return w
+}
+
+func (w *checksumWriter) Write(p []byte) (int, error) {
+	w.sha256.Write(p)
+	if w.sha512 != nil {
+		w.sha512.Write(p)
+	}
+	w.size += int64(len(p))
+	return len(p), nil
+}
+
+func (w *checksumWriter) artifact(pth string, modTime time.Time) ExportedArtifact {
+	artifact := ExportedArtifact{
+		Name:    filepath.Base(pth),
+		Path:    pth,
+		Size:    w.size,
+		SHA256:  hex.EncodeToString(w.sha256.Sum(nil)),
+		modTime: modTime,
+	}
+	if w.sha512 != nil {
+		artifact.SHA512 = hex.EncodeToString(w.sha512.Sum(nil))
+	}
+	return artifact
+}
+
+// copyFileWithChecksum copies the file and records its checksums computed while copying.
+func copyFileWithChecksum(sourcePth, destinationPth string) (err error) {
+	source, err := os.Open(sourcePth)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := source.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	info, err := source.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("source is a directory: %s", sourcePth)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(destinationPth), 0755); err != nil {
+		return err
+	}
+
+	destination, err := os.OpenFile(destinationPth, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := destination.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	checksum := newChecksumWriter()
+	if _, err := io.Copy(io.MultiWriter(destination, checksum), source); err != nil {
+		return fmt.Errorf("failed to copy %s to %s: %s", sourcePth, destinationPth, err)
+	}
+
+	destinationInfo, err := destination.Stat()
+	if err != nil {
+		return err
+	}
+
+	recordArtifact(checksum.artifact(destinationPth, destinationInfo.ModTime()))
+	return nil
+}
+
+// writeFileWithChecksum writes the file and records its checksums computed from the content.
+func writeFileWithChecksum(pth string, content []byte) error {
+	if err := fileutil.WriteBytesToFile(pth, content); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(pth)
+	if err != nil {
+		return err
+	}
+
+	checksum := newChecksumWriter()
+	if _, err := checksum.Write(content); err != nil {
+		return err
+	}
+
+	recordArtifact(checksum.artifact(pth, info.ModTime()))
+	return nil
+}
+
+// WriteJSONArtifact writes the JSON file, and records its checksums without reading it back.
+func WriteJSONArtifact(pth string, content interface{}) error {
+	bytes, err := json.Marshal(content)
+	if err != nil {
+		return fmt.Errorf("failed to JSON marshal the provided object: %s", err)
+	}
+	return writeFileWithChecksum(pth, bytes)
+}
+
+// recordFileChecksum records the checksums of a file which is exported in place, dirs are skipped.
+func recordFileChecksum(pth string) (err error) {
+	if isDir, err := pathutil.IsDirExists(pth); err != nil {
+		return err
+	} else if isDir {
+		return nil
+	}
+
+	f, err := os.Open(pth)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	checksum := newChecksumWriter()
+	if _, err := io.Copy(checksum, f); err != nil {
+		return err
+	}
+
+	recordArtifact(checksum.artifact(pth, info.ModTime()))
+	return nil
+}
+
+// WriteSHA256Sums writes the SHA-256 checksums of the artifacts in the format of coreutils' sha256sum.
+func WriteSHA256Sums(artifacts []ExportedArtifact, pth string) error {
+	var lines []string
+	for _, artifact := range artifacts {
+		lines = append(lines, fmt.Sprintf("%s  %s", artifact.SHA256, artifact.Name))
+	}
+	return fileutil.WriteStringToFile(pth, strings.Join(lines, "\n")+"\n")
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func sha256Hex(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestCopyFileWithChecksumIsRecorded(t *testing.T) {
+	exportedArtifacts = map[string]ExportedArtifact{}
+	dir := t.TempDir()
+
+	sourcePth := filepath.Join(dir, "source.pkg")
+	if err := os.WriteFile(sourcePth, []byte("pkg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	destinationPth := filepath.Join(dir, "deploy", "Test.pkg")
+	if err := copyFileWithChecksum(sourcePth, destinationPth); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := exportedArtifacts[destinationPth].SHA256; got != sha256Hex("pkg") {
+		t.Errorf("SHA256 = %s, want %s", got, sha256Hex("pkg"))
+	}
+	if recorded, err := isRecordedArtifact(destinationPth); err != nil {
+		t.Fatal(err)
+	} else if !recorded {
+		t.Errorf("the copied file is not recorded")
+	}
+
+	// a file rewritten in place has to be hashed again
+	if err := os.WriteFile(destinationPth, []byte("changed pkg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(destinationPth, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if recorded, err := isRecordedArtifact(destinationPth); err != nil {
+		t.Fatal(err)
+	} else if recorded {
+		t.Errorf("the changed file is reported as recorded")
+	}
+}
+
+func TestWriteJSONArtifact(t *testing.T) {
+	exportedArtifacts = map[string]ExportedArtifact{}
+	pth := filepath.Join(t.TempDir(), "Test.app.zip.metadata.json")
+
+	if err := WriteJSONArtifact(pth, map[string]string{"name": "Test.app.zip"}); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(pth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"name":"Test.app.zip"}`; string(content) != want {
+		t.Errorf("content = %s, want %s", content, want)
+	}
+
+	artifacts := ExportedArtifacts()
+	if len(artifacts) != 1 || artifacts[0].Name != "Test.app.zip.metadata.json" || artifacts[0].SHA256 != sha256Hex(string(content)) || artifacts[0].Size != int64(len(content)) {
+		t.Errorf("recorded artifacts = %+v", artifacts)
+	}
+	if recorded, err := isRecordedArtifact(pth); err != nil {
+		t.Fatal(err)
+	} else if !recorded {
+		t.Errorf("the written file is not recorded")
+	}
+}
@@ -1,34 +1,13 @@
 package utils
 
 import (
-	"fmt"
 	"path/filepath"
 	"strings"
 
 	"github.com/bitrise-io/go-utils/command"
-	"github.com/bitrise-io/go-utils/fileutil"
 	"github.com/bitrise-io/go-utils/pathutil"
 )
 
-func zip(sourceDir, destinationZipPth string) error {
-	parentDir := filepath.Dir(sourceDir)
-	dirName := filepath.Base(sourceDir)
-	cmd := command.New("/usr/bin/zip", "-rTy", destinationZipPth, dirName)
-	cmd.SetDir(parentDir)
-	out, err := cmd.RunAndReturnTrimmedCombinedOutput()
-	if err != nil {
-		return fmt.Errorf("Failed to zip dir: %s, output: %s, error: %s", sourceDir, out, err)
-	}
-
-	return nil
-}
-
-func exportEnvironmentWithEnvman(keyStr, valueStr string) error {
-	cmd := command.New("envman", "add", "--key", keyStr)
-	cmd.SetStdin(strings.NewReader(valueStr))
-	return cmd.Run()
-}
-
 // ExportOutputDir ...
 func ExportOutputDir(sourceDirPth, destinationDirPth, envKey string) error {
 	if sourceDirPth != destinationDirPth {
@@ -37,64 +16,80 @@ func ExportOutputDir(sourceDirPth, destinationDirPth, envKey string) error {
 		}
 	}
 
-	return exportEnvironmentWithEnvman(envKey, destinationDirPth)
+	return exportEnvironment(envKey, destinationDirPth)
 }
 
 // ExportOutputFile ...
 func ExportOutputFile(sourcePth, destinationPth, envKey string) error {
 	if sourcePth != destinationPth {
-		if err := command.CopyFile(sourcePth, destinationPth); err != nil {
+		if err := copyFileWithChecksum(sourcePth, destinationPth); err != nil {
+			return err
+		}
+	} else if recorded, err := isRecordedArtifact(destinationPth); err != nil {
+		return err
+	} else if !recorded {
+		// files already hashed while copying or writing are not read again
+		if err := recordFileChecksum(destinationPth); err != nil {
 			return err
 		}
 	}
+	setArtifactEnvKey(destinationPth, envKey)
 
-	return exportEnvironmentWithEnvman(envKey, destinationPth)
+	return exportEnvironment(envKey, destinationPth)
 }
 
 // ExportOutputFileContent ...
 func ExportOutputFileContent(content, destinationPth, envKey string) error {
-	if err := fileutil.WriteStringToFile(destinationPth, content); err != nil {
+	if err := writeFileWithChecksum(destinationPth, []byte(content)); err != nil {
 		return err
 	}
 
 	return ExportOutputFile(destinationPth, destinationPth, envKey)
 }
 
-// ExportOutputDirAsZip ...
-func ExportOutputDirAsZip(sourceDirPth, destinationPth, envKey string) error {
+// ExportOutputValue ...
+func ExportOutputValue(value, envKey string) error {
+	return exportEnvironment(envKey, value)
+}
+
+// ExportOutputList ...
+func ExportOutputList(pths []string, envKey string) error {
+	return exportEnvironment(envKey, strings.Join(pths, "|"))
+}
+
+// CopyFile ...
+func CopyFile(sourcePth, destinationPth string) error {
+	return copyFileWithChecksum(sourcePth, destinationPth)
+}
+
+// CopyDirsAsZip ...
+func CopyDirsAsZip(sourceDirPths []string, destinationPth string, options ...ZipOption) error {
 	tmpDir, err := pathutil.NormalizedOSTempDirPath("__export_tmp_dir__")
 	if err != nil {
 		return err
 	}
 
-	base := filepath.Base(sourceDirPth)
+	base := filepath.Base(destinationPth)
 	tmpZipFilePth := filepath.Join(tmpDir, base+".zip")
 
-	if err := zip(sourceDirPth, tmpZipFilePth); err != nil {
+	if err := zipDirs(sourceDirPths, tmpZipFilePth, options...); err != nil {
 		return err
 	}
 
-	return ExportOutputFile(tmpZipFilePth, destinationPth, envKey)
+	return copyFileWithChecksum(tmpZipFilePth, destinationPth)
 }
 
-// ExportAppFromArchive ...
-func ExportAppFromArchive(archivePath, destinationPath, envKey string) error {
-	appPattern := filepath.Join(archivePath, "Products/Applications/*.app")
-	pths, err := filepath.Glob(appPattern)
-	if err != nil {
-		return fmt.Errorf("failed to execute pattern, error: %s", err)
-	}
-
-	sourcePath := ""
-	if len(pths) > 0 {
-		sourcePath = pths[0]
-	} else {
-		return fmt.Errorf("failed to find main app, using pattern: %s", appPattern)
+// ExportOutputDirsAsZip ...
+func ExportOutputDirsAsZip(sourceDirPths []string, destinationPth, envKey string, options ...ZipOption) error {
+	if err := CopyDirsAsZip(sourceDirPths, destinationPth, options...); err != nil {
+		return err
 	}
+	setArtifactEnvKey(destinationPth, envKey)
 
-	if err := ExportOutputDirAsZip(sourcePath, destinationPath, envKey); err != nil {
-		return fmt.Errorf("failed to export %s, error: %s", envKey, err)
-	}
+	return exportEnvironment(envKey, destinationPth)
+}
 
-	return nil
+// ExportOutputDirAsZip ...
+func ExportOutputDirAsZip(sourceDirPth, destinationPth, envKey string, options ...ZipOption) error {
+	return ExportOutputDirsAsZip([]string{sourceDirPth}, destinationPth, envKey, options...)
 }
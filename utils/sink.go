@@ -0,0 +1,252 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+// Output sink names, used by the output_sink input.
+const (
+	OutputSinkAuto   = "auto"
+	OutputSinkEnvman = "envman"
+	OutputSinkGitHub = "github"
+	OutputSinkDotenv = "dotenv"
+	OutputSinkJSON   = "json"
+)
+
+const (
+	defaultDotenvName = "outputs.env"
+	defaultJSONName   = "outputs.json"
+)
+
+// OutputSink stores the step's outputs for the subsequent steps.
+type OutputSink interface {
+	// Name returns the name of the sink with its destination, if any.
+	Name() string
+	// Export stores the value of the output.
+	Export(key, value string) error
+}
+
+var outputSink OutputSink = envmanSink{}
+
+// SetOutputSink sets the sink used by the Export* functions, envman is used by default.
+func SetOutputSink(sink OutputSink) {
+	outputSink = sink
+}
+
+// NewOutputSink creates the output sink by name.
+// The auto sink is envman if it is on the PATH, GitHub Actions' $GITHUB_OUTPUT if set, a dotenv file otherwise.
+// The dotenv and JSON files are created at pth, or in the dir if pth is empty, the values of a previous run are removed from them.
+func NewOutputSink(name, pth, dir string) (OutputSink, error) {
+	if name == OutputSinkAuto {
+		switch {
+		case isEnvmanAvailable():
+			name = OutputSinkEnvman
+		case os.Getenv("GITHUB_OUTPUT") != "":
+			name = OutputSinkGitHub
+		default:
+			name = OutputSinkDotenv
+		}
+	}
+
+	switch name {
+	case OutputSinkEnvman:
+		return envmanSink{}, nil
+	case OutputSinkGitHub:
+		if pth == "" {
+			pth = os.Getenv("GITHUB_OUTPUT")
+		}
+		if pth == "" {
+			return nil, fmt.Errorf("GITHUB_OUTPUT is not set")
+		}
+		return githubOutputSink{pth: pth}, nil
+	case OutputSinkDotenv:
+		if pth == "" {
+			pth = filepath.Join(dir, defaultDotenvName)
+		}
+		if err := truncateFile(pth); err != nil {
+			return nil, fmt.Errorf("failed to create %s: %s", pth, err)
+		}
+		return dotenvSink{pth: pth}, nil
+	case OutputSinkJSON:
+		if pth == "" {
+			pth = filepath.Join(dir, defaultJSONName)
+		}
+		if err := truncateFile(pth); err != nil {
+			return nil, fmt.Errorf("failed to create %s: %s", pth, err)
+		}
+		return jsonSink{pth: pth}, nil
+	default:
+		return nil, fmt.Errorf("unknown output sink: %s, available sinks: %s", name, strings.Join([]string{OutputSinkAuto, OutputSinkEnvman, OutputSinkGitHub, OutputSinkDotenv, OutputSinkJSON}, ", "))
+	}
+}
+
+func isEnvmanAvailable() bool {
+	_, err := exec.LookPath("envman")
+	return err == nil
+}
+
+func exportEnvironment(key, value string) error {
+	return outputSink.Export(key, value)
+}
+
+// envmanSink adds the outputs to the Bitrise environment.
+type envmanSink struct{}
+
+func (envmanSink) Name() string {
+	return OutputSinkEnvman
+}
+
+func (envmanSink) Export(key, value string) error {
+	cmd := command.New("envman", "add", "--key", key)
+	cmd.SetStdin(strings.NewReader(value))
+	return cmd.Run()
+}
+
+// githubOutputSink appends the outputs to the GitHub Actions' $GITHUB_OUTPUT file, using the multiline syntax.
+type githubOutputSink struct {
+	pth string
+}
+
+func (s githubOutputSink) Name() string {
+	return fmt.Sprintf("%s (%s)", OutputSinkGitHub, s.pth)
+}
+
+func (s githubOutputSink) Export(key, value string) error {
+	var delimiter string
+	for delimiter == "" || !isValidHeredocDelimiter(value, delimiter) {
+		var err error
+		if delimiter, err = randomDelimiter(); err != nil {
+			return err
+		}
+	}
+	return appendToFile(s.pth, githubOutputEntry(key, value, delimiter))
+}
+
+func randomDelimiter() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "EOF_" + hex.EncodeToString(b), nil
+}
+
+// isValidHeredocDelimiter checks that no line of the value equals the delimiter, which would end the value early.
+func isValidHeredocDelimiter(value, delimiter string) bool {
+	for _, line := range strings.Split(value, "\n") {
+		if strings.TrimSuffix(line, "\r") == delimiter {
+			return false
+		}
+	}
+	return true
+}
+
+// githubOutputEntry returns the output in the multiline syntax of $GITHUB_OUTPUT.
+func githubOutputEntry(key, value, delimiter string) string {
+	return fmt.Sprintf("%s<<%s\n%s\n%s\n", key, delimiter, value, delimiter)
+}
+
+// dotenvSink appends the outputs to a dotenv file as double quoted values, a later value of the same key overrides the earlier one.
+type dotenvSink struct {
+	pth string
+}
+
+func (s dotenvSink) Name() string {
+	return fmt.Sprintf("%s (%s)", OutputSinkDotenv, s.pth)
+}
+
+func (s dotenvSink) Export(key, value string) error {
+	return appendToFile(s.pth, fmt.Sprintf("%s=%s\n", key, dotenvQuote(value)))
+}
+
+// dotenvEscaper escapes the characters which are special in the double quoted values of dotenv files:
+// the escape character, the quote, the variable expansion and the line breaks.
+var dotenvEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	`$`, `\$`,
+	"\n", `\n`,
+	"\r", `\r`,
+)
+
+// dotenvQuote returns the value as a double quoted dotenv value.
+func dotenvQuote(value string) string {
+	return `"` + dotenvEscaper.Replace(value) + `"`
+}
+
+// jsonSink stores the outputs in a JSON object, keyed by the output name.
+type jsonSink struct {
+	pth string
+}
+
+func (s jsonSink) Name() string {
+	return fmt.Sprintf("%s (%s)", OutputSinkJSON, s.pth)
+}
+
+func (s jsonSink) Export(key, value string) error {
+	var content []byte
+	if exist, err := pathutil.IsPathExists(s.pth); err != nil {
+		return err
+	} else if exist {
+		if content, err = fileutil.ReadBytesFromFile(s.pth); err != nil {
+			return err
+		}
+	}
+
+	merged, err := mergeJSONOutput(content, key, value)
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %s", s.pth, err)
+	}
+	return fileutil.WriteBytesToFile(s.pth, merged)
+}
+
+// mergeJSONOutput sets the output in the JSON object of the outputs, an empty content is an empty object.
+func mergeJSONOutput(content []byte, key, value string) ([]byte, error) {
+	outputs := map[string]string{}
+	if len(bytes.TrimSpace(content)) > 0 {
+		if err := json.Unmarshal(content, &outputs); err != nil {
+			return nil, fmt.Errorf("failed to parse outputs: %s", err)
+		}
+	}
+
+	outputs[key] = value
+	return json.Marshal(outputs)
+}
+
+// truncateFile creates the file or empties it if it exists.
+func truncateFile(pth string) error {
+	if err := os.MkdirAll(filepath.Dir(pth), 0755); err != nil {
+		return err
+	}
+	return fileutil.WriteBytesToFile(pth, nil)
+}
+
+func appendToFile(pth, content string) (err error) {
+	if err := os.MkdirAll(filepath.Dir(pth), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(pth, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	_, err = f.WriteString(content)
+	return err
+}
@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGithubOutputEntry(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		delimiter string
+		want      string
+		valid     bool
+	}{
+		{
+			name:      "single line",
+			value:     "/deploy/Test.app.zip",
+			delimiter: "EOF_0123",
+			want:      "BITRISE_APP_ZIP_PATH<<EOF_0123\n/deploy/Test.app.zip\nEOF_0123\n",
+			valid:     true,
+		},
+		{
+			name:      "multiline",
+			value:     "first\nsecond",
+			delimiter: "EOF_0123",
+			want:      "BITRISE_APP_ZIP_PATH<<EOF_0123\nfirst\nsecond\nEOF_0123\n",
+			valid:     true,
+		},
+		{
+			name:      "delimiter inside a line",
+			value:     "first EOF_0123\nsecond",
+			delimiter: "EOF_0123",
+			want:      "BITRISE_APP_ZIP_PATH<<EOF_0123\nfirst EOF_0123\nsecond\nEOF_0123\n",
+			valid:     true,
+		},
+		{
+			name:      "delimiter as a line",
+			value:     "first\nEOF_0123\r\nsecond",
+			delimiter: "EOF_0123",
+			valid:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isValidHeredocDelimiter(tt.value, tt.delimiter); got != tt.valid {
+				t.Fatalf("isValidHeredocDelimiter() = %t, want %t", got, tt.valid)
+			}
+			if !tt.valid {
+				return
+			}
+			if got := githubOutputEntry("BITRISE_APP_ZIP_PATH", tt.value, tt.delimiter); got != tt.want {
+				t.Errorf("githubOutputEntry() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDotenvQuote(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "", want: `""`},
+		{value: "/deploy/Test.app.zip", want: `"/deploy/Test.app.zip"`},
+		{value: "/deploy/Test $HOME.app", want: `"/deploy/Test \$HOME.app"`},
+		{value: `say "hi"`, want: `"say \"hi\""`},
+		{value: `C:\deploy`, want: `"C:\\deploy"`},
+		{value: "first\nsecond\r\n", want: `"first\nsecond\r\n"`},
+		{value: "Árvíztűrő tükörfúrógép", want: `"Árvíztűrő tükörfúrógép"`},
+	}
+
+	for _, tt := range tests {
+		if got := dotenvQuote(tt.value); got != tt.want {
+			t.Errorf("dotenvQuote(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestMergeJSONOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr bool
+	}{
+		{name: "empty file", content: "", want: `{"KEY":"new"}`},
+		{name: "other output", content: `{"OTHER":"value"}`, want: `{"KEY":"new","OTHER":"value"}`},
+		{name: "same output", content: `{"KEY":"old"}`, want: `{"KEY":"new"}`},
+		{name: "invalid content", content: `KEY=old`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mergeJSONOutput([]byte(tt.content), "KEY", "new")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewOutputSinkTruncatesDotenvFile(t *testing.T) {
+	pth := filepath.Join(t.TempDir(), "outputs.env")
+	if err := os.WriteFile(pth, []byte("STALE=\"value\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sink, err := NewOutputSink(OutputSinkDotenv, pth, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range []string{"first", "second"} {
+		if err := sink.Export("KEY", value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	content, err := os.ReadFile(pth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "STALE") {
+		t.Errorf("the value of the previous run is kept: %s", content)
+	}
+	if want := "KEY=\"first\"\nKEY=\"second\"\n"; string(content) != want {
+		t.Errorf("content = %q, want %q", content, want)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !darwin && !linux
+
+package utils
+
+// readXattrs is a no-op on platforms without extended attribute support.
+func readXattrs(pth string) (map[string][]byte, error) {
+	return nil, nil
+}
@@ -0,0 +1,70 @@
+//go:build darwin || linux
+
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// linuxSystemXattrPrefixes are the namespaces of the Linux extended attributes managed by the system
+// (security labels, ACLs and trusted attributes), they are not stored in the AppleDouble entries.
+var linuxSystemXattrPrefixes = []string{"security.", "system.", "trusted."}
+
+func isSystemXattr(name string) bool {
+	if runtime.GOOS != "linux" {
+		return false
+	}
+	for _, prefix := range linuxSystemXattrPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// readXattrs returns the extended attributes of the file at the given path, symlinks are not followed.
+// The Linux system namespaces are skipped (see linuxSystemXattrPrefixes).
+func readXattrs(pth string) (map[string][]byte, error) {
+	size, err := unix.Llistxattr(pth, nil)
+	if err != nil {
+		if errors.Is(err, unix.ENOTSUP) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if size == 0 {
+		return nil, nil
+	}
+
+	names := make([]byte, size)
+	size, err = unix.Llistxattr(pth, names)
+	if err != nil {
+		return nil, err
+	}
+
+	xattrs := map[string][]byte{}
+	for _, name := range bytes.Split(names[:size], []byte{0}) {
+		if len(name) == 0 || isSystemXattr(string(name)) {
+			continue
+		}
+
+		valueSize, err := unix.Lgetxattr(pth, string(name), nil)
+		if err != nil {
+			return nil, err
+		}
+		value := make([]byte, valueSize)
+		valueSize, err = unix.Lgetxattr(pth, string(name), value)
+		if err != nil {
+			return nil, err
+		}
+
+		xattrs[string(name)] = value[:valueSize]
+	}
+
+	return xattrs, nil
+}
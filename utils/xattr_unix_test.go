@@ -0,0 +1,109 @@
+//go:build darwin || linux
+
+package utils
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// testXattrName is a user attribute name, which has to be in the user namespace on Linux.
+func testXattrName() string {
+	if runtime.GOOS == "linux" {
+		return "user.com.example.test"
+	}
+	return "com.example.test"
+}
+
+func setTestXattr(t *testing.T, pth, name string, value []byte) {
+	t.Helper()
+
+	if err := unix.Setxattr(pth, name, value, 0); err != nil {
+		if errors.Is(err, unix.ENOTSUP) {
+			t.Skipf("extended attributes are not supported in %s", filepath.Dir(pth))
+		}
+		t.Fatal(err)
+	}
+}
+
+func TestReadXattrsSkipsLinuxSystemNamespaces(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the system namespaces are Linux specific")
+	}
+
+	appPth := createTestApp(t, t.TempDir())
+	pth := filepath.Join(appPth, "Contents", "Info.plist")
+	setTestXattr(t, pth, testXattrName(), []byte("hello"))
+	// setting a trusted attribute requires CAP_SYS_ADMIN
+	trustedSet := unix.Setxattr(pth, "trusted.test", []byte("trusted"), 0) == nil
+
+	xattrs, err := readXattrs(pth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string][]byte{testXattrName(): []byte("hello")}; !reflect.DeepEqual(xattrs, want) {
+		t.Errorf("readXattrs() = %q, want %q (trusted attribute set: %t)", xattrs, want, trustedSet)
+	}
+}
+
+func TestZipDirsWithAppleDouble(t *testing.T) {
+	dir := t.TempDir()
+	appPth := createTestApp(t, dir)
+	setTestXattr(t, filepath.Join(appPth, "Contents", "Info.plist"), testXattrName(), []byte("hello"))
+
+	zipPth := filepath.Join(dir, "Test.app.zip")
+	if err := zipDirs([]string{appPth}, zipPth, WithAppleDouble()); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := readZipEntries(t, zipPth)
+	var appleDoubleNames []string
+	for name := range entries {
+		if strings.HasPrefix(name, macosxDir+"/") {
+			appleDoubleNames = append(appleDoubleNames, name)
+		}
+	}
+	if want := []string{"__MACOSX/Test.app/Contents/._Info.plist"}; !reflect.DeepEqual(appleDoubleNames, want) {
+		t.Fatalf("AppleDouble entries = %v, want %v", appleDoubleNames, want)
+	}
+
+	f := entries["__MACOSX/Test.app/Contents/._Info.plist"]
+	if f.Mode().Perm() != 0644 || !f.Modified.Equal(zipModTime) {
+		t.Errorf("mode = %s, modified = %s", f.Mode(), f.Modified)
+	}
+
+	want, err := encodeAppleDouble(map[string][]byte{testXattrName(): []byte("hello")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readZipEntry(t, f); got != string(want) {
+		t.Errorf("AppleDouble content = %x, want %x", got, want)
+	}
+
+	if got := readZipEntry(t, entries["Test.app/Contents/Info.plist"]); got != "Info.plist" {
+		t.Errorf("Info.plist content = %s", got)
+	}
+}
+
+func TestZipDirsWithoutAppleDouble(t *testing.T) {
+	dir := t.TempDir()
+	appPth := createTestApp(t, dir)
+	setTestXattr(t, filepath.Join(appPth, "Contents", "Info.plist"), testXattrName(), []byte("hello"))
+
+	zipPth := filepath.Join(dir, "Test.app.zip")
+	if err := zipDirs([]string{appPth}, zipPth); err != nil {
+		t.Fatal(err)
+	}
+
+	for name := range readZipEntries(t, zipPth) {
+		if strings.HasPrefix(name, macosxDir+"/") {
+			t.Errorf("unexpected AppleDouble entry: %s", name)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package utils
+
+import (
+	archivezip "archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// zipModTime is the modification time of every zip entry, so that the same input always results in the same zip.
+// It is the earliest time the MS-DOS date format of the zip headers can represent.
+var zipModTime = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// ZipOption configures the layout of the created zip files.
+type ZipOption func(*zipOptions)
+
+type zipOptions struct {
+	appleDouble bool
+}
+
+// WithAppleDouble stores the extended attributes and resource forks of the zipped files as AppleDouble entries
+// under __MACOSX, the way `ditto -c -k --sequesterRsrc --keepParent` does.
+func WithAppleDouble() ZipOption {
+	return func(opts *zipOptions) {
+		opts.appleDouble = true
+	}
+}
+
+// zipDirs zips the given dirs (or files) into a single zip file, they have to share the same parent dir.
+// Entries are stored relative to the parent dir in lexical order with normalized timestamps,
+// symlinks are stored as links and permission bits are kept.
+func zipDirs(sourceDirs []string, destinationZipPth string, options ...ZipOption) (err error) {
+	opts := zipOptions{}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	if len(sourceDirs) == 0 {
+		return fmt.Errorf("no dirs to zip")
+	}
+
+	parentDir := filepath.Dir(sourceDirs[0])
+	for _, sourceDir := range sourceDirs {
+		if filepath.Dir(sourceDir) != parentDir {
+			return fmt.Errorf("dirs to zip have different parent dirs: %s, %s", parentDir, filepath.Dir(sourceDir))
+		}
+	}
+
+	sorted := append([]string{}, sourceDirs...)
+	sort.Strings(sorted)
+
+	zipFile, err := os.Create(destinationZipPth)
+	if err != nil {
+		return fmt.Errorf("failed to create zip file (%s): %s", destinationZipPth, err)
+	}
+	defer func() {
+		if cerr := zipFile.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	zipWriter := archivezip.NewWriter(zipFile)
+	for _, sourceDir := range sorted {
+		if err := filepath.Walk(sourceDir, func(pth string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			return addZipEntry(zipWriter, parentDir, pth, info, opts)
+		}); err != nil {
+			return fmt.Errorf("failed to zip %s: %s", sourceDir, err)
+		}
+	}
+
+	return zipWriter.Close()
+}
+
+func addZipEntry(zipWriter *archivezip.Writer, parentDir, pth string, info os.FileInfo, opts zipOptions) error {
+	relPth, err := filepath.Rel(parentDir, pth)
+	if err != nil {
+		return err
+	}
+
+	if err := addFileZipEntry(zipWriter, filepath.ToSlash(relPth), pth, info); err != nil {
+		return err
+	}
+
+	if opts.appleDouble {
+		return addAppleDoubleZipEntry(zipWriter, filepath.ToSlash(relPth), pth)
+	}
+	return nil
+}
+
+func addAppleDoubleZipEntry(zipWriter *archivezip.Writer, name, pth string) error {
+	xattrs, err := readXattrs(pth)
+	if err != nil {
+		return fmt.Errorf("failed to read extended attributes of %s: %s", pth, err)
+	}
+	if len(xattrs) == 0 {
+		return nil
+	}
+
+	content, err := encodeAppleDouble(xattrs)
+	if err != nil {
+		return fmt.Errorf("failed to encode extended attributes of %s: %s", pth, err)
+	}
+
+	header := &archivezip.FileHeader{
+		Name:     appleDoubleName(name),
+		Method:   archivezip.Deflate,
+		Modified: zipModTime,
+	}
+	header.SetMode(0644)
+
+	writer, err := zipWriter.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(content)
+	return err
+}
+
+func addFileZipEntry(zipWriter *archivezip.Writer, name, pth string, info os.FileInfo) error {
+	header := &archivezip.FileHeader{
+		Name:     name,
+		Modified: zipModTime,
+	}
+	header.SetMode(info.Mode())
+
+	switch {
+	case info.IsDir():
+		header.Name += "/"
+		header.Method = archivezip.Store
+		_, err := zipWriter.CreateHeader(header)
+		return err
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(pth)
+		if err != nil {
+			return err
+		}
+
+		header.Method = archivezip.Store
+		writer, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(writer, strings.NewReader(target))
+		return err
+	case info.Mode().IsRegular():
+		header.Method = archivezip.Deflate
+		writer, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		return copyFileContent(writer, pth)
+	default:
+		return fmt.Errorf("unsupported file type: %s (%s)", pth, info.Mode().Type())
+	}
+}
+
+func copyFileContent(writer io.Writer, pth string) (err error) {
+	f, err := os.Open(pth)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	_, err = io.Copy(writer, f)
+	return err
+}
@@ -0,0 +1,205 @@
+package utils
+
+import (
+	archivezip "archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// createTestApp creates an app bundle with an executable, a framework with versioned symlinks and an empty dir.
+func createTestApp(t *testing.T, dir string) string {
+	t.Helper()
+
+	appPth := filepath.Join(dir, "Test.app")
+	frameworkPth := filepath.Join(appPth, "Contents", "Frameworks", "Test.framework")
+	for _, pth := range []string{
+		filepath.Join(appPth, "Contents", "MacOS"),
+		filepath.Join(appPth, "Contents", "Resources"),
+		filepath.Join(frameworkPth, "Versions", "A", "Resources"),
+	} {
+		if err := os.MkdirAll(pth, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files := map[string]os.FileMode{
+		filepath.Join(appPth, "Contents", "Info.plist"):                0644,
+		filepath.Join(appPth, "Contents", "MacOS", "Test"):             0755,
+		filepath.Join(frameworkPth, "Versions", "A", "Test"):           0755,
+		filepath.Join(frameworkPth, "Versions", "A", "Resources", "a"): 0644,
+	}
+	for pth, mode := range files {
+		if err := os.WriteFile(pth, []byte(filepath.Base(pth)), mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(pth, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	symlinks := map[string]string{
+		filepath.Join(frameworkPth, "Versions", "Current"): "A",
+		filepath.Join(frameworkPth, "Test"):                "Versions/Current/Test",
+		filepath.Join(frameworkPth, "Resources"):           "Versions/Current/Resources",
+	}
+	for pth, target := range symlinks {
+		if err := os.Symlink(target, pth); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return appPth
+}
+
+func readZipEntries(t *testing.T, pth string) map[string]*archivezip.File {
+	t.Helper()
+
+	reader, err := archivezip.OpenReader(pth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := reader.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	entries := map[string]*archivezip.File{}
+	for _, f := range reader.File {
+		entries[f.Name] = f
+	}
+	return entries
+}
+
+func readZipEntry(t *testing.T, f *archivezip.File) string {
+	t.Helper()
+
+	r, err := f.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := r.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	content, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestZipDirsIsReproducible(t *testing.T) {
+	dir := t.TempDir()
+	appPth := createTestApp(t, dir)
+
+	firstZipPth := filepath.Join(dir, "first.zip")
+	if err := zipDirs([]string{appPth}, firstZipPth); err != nil {
+		t.Fatal(err)
+	}
+
+	modTime := time.Now().Add(-48 * time.Hour)
+	if err := filepath.Walk(appPth, func(pth string, info os.FileInfo, err error) error {
+		if err != nil || info.Mode()&os.ModeSymlink != 0 {
+			return err
+		}
+		return os.Chtimes(pth, modTime, modTime)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	secondZipPth := filepath.Join(dir, "second.zip")
+	if err := zipDirs([]string{appPth}, secondZipPth); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := os.ReadFile(firstZipPth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := os.ReadFile(secondZipPth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Fatalf("zips of the same tree with different mtimes differ")
+	}
+}
+
+func TestZipDirsStoresSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	appPth := createTestApp(t, dir)
+
+	zipPth := filepath.Join(dir, "Test.app.zip")
+	if err := zipDirs([]string{appPth}, zipPth); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := readZipEntries(t, zipPth)
+	for name, target := range map[string]string{
+		"Test.app/Contents/Frameworks/Test.framework/Versions/Current": "A",
+		"Test.app/Contents/Frameworks/Test.framework/Test":             "Versions/Current/Test",
+		"Test.app/Contents/Frameworks/Test.framework/Resources":        "Versions/Current/Resources",
+	} {
+		f, ok := entries[name]
+		if !ok {
+			t.Errorf("%s: entry not found", name)
+			continue
+		}
+		if f.Mode()&os.ModeSymlink == 0 {
+			t.Errorf("%s: not stored as a symlink, mode: %s", name, f.Mode())
+			continue
+		}
+		if got := readZipEntry(t, f); got != target {
+			t.Errorf("%s: link target = %s, want %s", name, got, target)
+		}
+	}
+}
+
+func TestZipDirsKeepsModesAndDirs(t *testing.T) {
+	dir := t.TempDir()
+	appPth := createTestApp(t, dir)
+
+	zipPth := filepath.Join(dir, "Test.app.zip")
+	if err := zipDirs([]string{appPth}, zipPth); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := readZipEntries(t, zipPth)
+	for name, want := range map[string]os.FileMode{
+		"Test.app/Contents/MacOS/Test":                                0755,
+		"Test.app/Contents/Info.plist":                                0644,
+		"Test.app/Contents/Frameworks/Test.framework/Versions/A/Test": 0755,
+	} {
+		f, ok := entries[name]
+		if !ok {
+			t.Errorf("%s: entry not found", name)
+			continue
+		}
+		if got := f.Mode(); !got.IsRegular() || got.Perm() != want {
+			t.Errorf("%s: mode = %s, want %s", name, got, want)
+		}
+	}
+
+	for _, name := range []string{
+		"Test.app/",
+		"Test.app/Contents/",
+		"Test.app/Contents/Resources/",
+		"Test.app/Contents/Frameworks/Test.framework/Versions/A/Resources/",
+	} {
+		f, ok := entries[name]
+		if !ok {
+			t.Errorf("%s: directory entry not found", name)
+			continue
+		}
+		if !f.Mode().IsDir() {
+			t.Errorf("%s: not stored as a directory, mode: %s", name, f.Mode())
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-io/go-xcode/models"
+	"github.com/bitrise-steplib/steps-export-xcarchive-mac/utils"
+)
+
+const (
+	developerDirEnvKey             = "DEVELOPER_DIR"
+	bitriseXcodeVersionEnvKey      = "BITRISE_XCODE_VERSION"
+	bitriseXcodeBuildVersionEnvKey = "BITRISE_XCODE_BUILD_VERSION"
+	bitriseXcodeDeveloperDirEnvKey = "BITRISE_XCODE_DEVELOPER_DIR"
+)
+
+// resolveDeveloperDir returns the Developer dir of the Xcode, the path is either the Xcode app or its Developer dir.
+func resolveDeveloperDir(xcodePth string) (string, error) {
+	developerDir := xcodePth
+	if strings.HasSuffix(strings.TrimSuffix(xcodePth, "/"), ".app") {
+		developerDir = filepath.Join(xcodePth, "Contents", "Developer")
+	}
+
+	if exist, err := pathutil.IsDirExists(developerDir); err != nil {
+		return "", fmt.Errorf("failed to check if %s exists: %s", developerDir, err)
+	} else if !exist {
+		return "", fmt.Errorf("Xcode Developer dir not exist at: %s", developerDir)
+	}
+	return filepath.Clean(developerDir), nil
+}
+
+// selectedDeveloperDir returns the Developer dir used by xcodebuild: DEVELOPER_DIR if set, the xcode-select'ed one otherwise.
+func selectedDeveloperDir() (string, error) {
+	if developerDir := os.Getenv(developerDirEnvKey); developerDir != "" {
+		return developerDir, nil
+	}
+
+	out, err := command.New("xcode-select", "-p").RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("xcode-select -p failed, error: %s, details: %s", err, out)
+	}
+	return out, nil
+}
+
+// xcodeVersionNumber returns the version number of the xcodebuild version, for example 15.2 of Xcode 15.2.
+func xcodeVersionNumber(version models.XcodebuildVersionModel) string {
+	return strings.TrimSpace(strings.TrimPrefix(version.Version, "Xcode"))
+}
+
+// xcodeBuildVersion returns the build version of the xcodebuild version, for example 15C500b.
+func xcodeBuildVersion(version models.XcodebuildVersionModel) string {
+	return strings.TrimSpace(strings.TrimPrefix(version.BuildVersion, "Build version"))
+}
+
+// parseXcodeVersion parses a dot separated version number.
+func parseXcodeVersion(version string) ([]int, error) {
+	var components []int
+	for _, component := range strings.Split(version, ".") {
+		number, err := strconv.Atoi(component)
+		if err != nil || number < 0 {
+			return nil, fmt.Errorf("invalid Xcode version: %s", version)
+		}
+		components = append(components, number)
+	}
+	return components, nil
+}
+
+// compareXcodeVersion compares the version to the constraint on the constraint's components only,
+// so that 15.4 matches the constraint 15 as both a minimum and a maximum.
+func compareXcodeVersion(version, constraint []int) int {
+	for i, component := range constraint {
+		versionComponent := 0
+		if i < len(version) {
+			versionComponent = version[i]
+		}
+
+		if versionComponent < component {
+			return -1
+		} else if versionComponent > component {
+			return 1
+		}
+	}
+	return 0
+}
+
+// checkXcodeVersion returns an error if the version does not satisfy the optional minimum and maximum versions.
+func checkXcodeVersion(version, minVersion, maxVersion string) error {
+	components, err := parseXcodeVersion(version)
+	if err != nil {
+		return err
+	}
+
+	if minVersion != "" {
+		minComponents, err := parseXcodeVersion(minVersion)
+		if err != nil {
+			return err
+		}
+		if compareXcodeVersion(components, minComponents) < 0 {
+			return fmt.Errorf("Xcode %s is older than the minimum version: %s", version, minVersion)
+		}
+	}
+
+	if maxVersion != "" {
+		maxComponents, err := parseXcodeVersion(maxVersion)
+		if err != nil {
+			return err
+		}
+		if compareXcodeVersion(components, maxComponents) > 0 {
+			return fmt.Errorf("Xcode %s is newer than the maximum version: %s", version, maxVersion)
+		}
+	}
+
+	return nil
+}
+
+// exportXcodeSelection exports the version and the Developer dir of the Xcode used by the step.
+func exportXcodeSelection(version models.XcodebuildVersionModel, developerDir string) error {
+	values := map[string]string{
+		bitriseXcodeVersionEnvKey:      xcodeVersionNumber(version),
+		bitriseXcodeBuildVersionEnvKey: xcodeBuildVersion(version),
+		bitriseXcodeDeveloperDirEnvKey: developerDir,
+	}
+	for _, key := range []string{bitriseXcodeVersionEnvKey, bitriseXcodeBuildVersionEnvKey, bitriseXcodeDeveloperDirEnvKey} {
+		if err := utils.ExportOutputValue(values[key], key); err != nil {
+			return fmt.Errorf("failed to export %s: %s", key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// maxOutputTailLines is the number of the last xcodebuild output lines kept for the error summary.
+const maxOutputTailLines = 200
+
+// maxOutputLineLength is the length of the longest kept output line, longer lines are split, so that the memory use stays flat.
+const maxOutputLineLength = 64 * 1024
+
+// Formats of the xcodebuild output, used by the xcodebuild_output_format input.
+const (
+	xcodebuildOutputFormatRaw    = "raw"
+	xcodebuildOutputFormatPretty = "pretty"
+)
+
+var (
+	ideDistributionLogsPathRegexp = regexp.MustCompile(`IDEDistribution: -\[IDEDistributionLogging _createLoggingBundleAtPath:\]: Created bundle at path "(?P<log_path>.*)"`)
+	exportResultRegexp            = regexp.MustCompile(`\*\* EXPORT (SUCCEEDED|FAILED) \*\*`)
+	exportedToRegexp              = regexp.MustCompile(`^Exported .+ to: `)
+)
+
+// tailBuffer keeps the last lines written into it.
+type tailBuffer struct {
+	lines []string
+	next  int
+	full  bool
+}
+
+func newTailBuffer(size int) *tailBuffer {
+	return &tailBuffer{lines: make([]string, size)}
+}
+
+func (b *tailBuffer) add(line string) {
+	b.lines[b.next] = line
+	b.next = (b.next + 1) % len(b.lines)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// all returns the kept lines in the order they were written.
+func (b *tailBuffer) all() []string {
+	if !b.full {
+		return append([]string{}, b.lines[:b.next]...)
+	}
+	return append(append([]string{}, b.lines[b.next:]...), b.lines[:b.next]...)
+}
+
+// xcodebuildOutput processes the xcodebuild output line by line as it arrives:
+// writes it to out, prints the important lines in pretty format, looks for the xcdistributionlogs path, classifies the errors and keeps the last lines.
+type xcodebuildOutput struct {
+	out        io.Writer
+	pretty     bool
+	partial    []byte
+	tail       *tailBuffer
+	classifier *exportFailureClassifier
+
+	// IDEDistributionLogsPath is the xcdistributionlogs bundle's path, if found in the output.
+	IDEDistributionLogsPath string
+}
+
+func newXcodebuildOutput(out io.Writer, format string) *xcodebuildOutput {
+	return &xcodebuildOutput{
+		out:        out,
+		pretty:     format == xcodebuildOutputFormatPretty,
+		tail:       newTailBuffer(maxOutputTailLines),
+		classifier: newExportFailureClassifier(),
+	}
+}
+
+// Write splits the written bytes into lines, the incomplete last line is kept until the next write or Close.
+// An incomplete line longer than maxOutputLineLength is processed in parts of that length.
+func (o *xcodebuildOutput) Write(p []byte) (int, error) {
+	o.partial = append(o.partial, p...)
+	for {
+		idx := bytes.IndexByte(o.partial, '\n')
+		if idx == -1 {
+			break
+		}
+
+		line := strings.TrimSuffix(string(o.partial[:idx]), "\r")
+		o.partial = o.partial[idx+1:]
+		if err := o.processLine(line); err != nil {
+			return 0, err
+		}
+	}
+
+	for len(o.partial) > maxOutputLineLength {
+		// the line is split on a UTF-8 character boundary
+		cut := maxOutputLineLength
+		for cut > 0 && !utf8.RuneStart(o.partial[cut]) {
+			cut--
+		}
+		if cut == 0 {
+			cut = maxOutputLineLength
+		}
+
+		line := string(o.partial[:cut])
+		o.partial = append([]byte{}, o.partial[cut:]...)
+		if err := o.processLine(line); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Close processes the incomplete last line.
+func (o *xcodebuildOutput) Close() error {
+	if len(o.partial) == 0 {
+		return nil
+	}
+
+	line := string(o.partial)
+	o.partial = nil
+	return o.processLine(line)
+}
+
+func (o *xcodebuildOutput) processLine(line string) error {
+	o.tail.add(line)
+	o.classifier.addLine(line)
+
+	if match := ideDistributionLogsPathRegexp.FindStringSubmatch(line); len(match) == 2 {
+		o.IDEDistributionLogsPath = match[1]
+	}
+
+	if _, err := fmt.Fprintln(o.out, line); err != nil {
+		return err
+	}
+	if o.pretty {
+		printPrettyLine(line)
+	}
+	return nil
+}
+
+// printPrettyLine prints the errors, warnings and the result of the export only.
+func printPrettyLine(line string) {
+	trimmed := strings.TrimSpace(line)
+	lower := strings.ToLower(trimmed)
+
+	switch {
+	case exportResultRegexp.MatchString(trimmed):
+		if strings.Contains(trimmed, "SUCCEEDED") {
+			log.Donef("%s", trimmed)
+		} else {
+			log.Errorf("%s", trimmed)
+		}
+	case strings.Contains(lower, "error:"):
+		log.Errorf("%s", trimmed)
+	case strings.Contains(lower, "warning:"):
+		log.Warnf("%s", trimmed)
+	case exportedToRegexp.MatchString(trimmed):
+		log.Printf("%s", trimmed)
+	}
+}
+
+// Failures returns the classified export errors found in the output.
+func (o *xcodebuildOutput) Failures() []exportFailure {
+	return o.classifier.result(o.tail.all())
+}
+
+// Tail returns the last lines of the output.
+func (o *xcodebuildOutput) Tail() []string {
+	return o.tail.all()
+}
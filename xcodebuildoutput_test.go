@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestTailBuffer(t *testing.T) {
+	tests := []struct {
+		name  string
+		added int
+		want  []string
+	}{
+		{name: "empty", added: 0, want: []string{}},
+		{name: "not full", added: 2, want: []string{"0", "1"}},
+		{name: "exactly full", added: 3, want: []string{"0", "1", "2"}},
+		{name: "wrapped around", added: 5, want: []string{"2", "3", "4"}},
+		{name: "wrapped around twice", added: 7, want: []string{"4", "5", "6"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buffer := newTailBuffer(3)
+			for i := 0; i < tt.added; i++ {
+				buffer.add(strconv.Itoa(i))
+			}
+
+			if got := buffer.all(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("all() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestXcodebuildOutputSplitsLines(t *testing.T) {
+	var out bytes.Buffer
+	output := newXcodebuildOutput(&out, xcodebuildOutputFormatRaw)
+
+	for _, chunk := range []string{
+		"first li",
+		"ne\r\nsecond line\nthi",
+		"rd ",
+		"line without newline",
+	} {
+		n, err := output.Write([]byte(chunk))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(chunk) {
+			t.Fatalf("Write() = %d, want %d", n, len(chunk))
+		}
+	}
+
+	if got, want := output.Tail(), []string{"first line", "second line"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Tail() before Close = %v, want %v", got, want)
+	}
+
+	if err := output.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := output.Tail(), []string{"first line", "second line", "third line without newline"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Tail() after Close = %v, want %v", got, want)
+	}
+	if got, want := out.String(), "first line\nsecond line\nthird line without newline\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+
+	if err := output.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(output.Tail()); got != 3 {
+		t.Errorf("second Close added a line, %d lines kept", got)
+	}
+}
+
+func TestXcodebuildOutputFindsDistributionLogs(t *testing.T) {
+	var out bytes.Buffer
+	output := newXcodebuildOutput(&out, xcodebuildOutputFormatRaw)
+
+	line := `2026-10-19 10:00:00.000 xcodebuild[1234:5678] [MT] IDEDistribution: -[IDEDistributionLogging _createLoggingBundleAtPath:]: Created bundle at path "/var/folders/tmp/Sample_2026-10-19_10-00-00.000.xcdistributionlogs".`
+	if _, err := output.Write([]byte(line)); err != nil {
+		t.Fatal(err)
+	}
+	if err := output.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "/var/folders/tmp/Sample_2026-10-19_10-00-00.000.xcdistributionlogs"; output.IDEDistributionLogsPath != want {
+		t.Errorf("IDEDistributionLogsPath = %s, want %s", output.IDEDistributionLogsPath, want)
+	}
+}
+
+func TestXcodebuildOutputSplitsLongLines(t *testing.T) {
+	var out bytes.Buffer
+	output := newXcodebuildOutput(&out, xcodebuildOutputFormatRaw)
+
+	// the line arrives in small chunks without newline, a 2 byte character spans the limit
+	long := strings.Repeat("a", maxOutputLineLength-1) + "é" + strings.Repeat("b", 10)
+	for i := 0; i < len(long); i += 1000 {
+		end := i + 1000
+		if end > len(long) {
+			end = len(long)
+		}
+		if _, err := output.Write([]byte(long[i:end])); err != nil {
+			t.Fatal(err)
+		}
+		if len(output.partial) > maxOutputLineLength {
+			t.Fatalf("partial line grew to %d bytes", len(output.partial))
+		}
+	}
+	if _, err := output.Write([]byte("\nnext\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{strings.Repeat("a", maxOutputLineLength-1), "é" + strings.Repeat("b", 10), "next"}
+	if got := output.Tail(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Tail() has %d lines, want %d split on the character boundary", len(got), len(want))
+	}
+}